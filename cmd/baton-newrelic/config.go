@@ -3,26 +3,65 @@ package main
 import (
 	"context"
 	"fmt"
+	"net/url"
+	"strings"
 
-	"github.com/conductorone/baton-sdk/pkg/cli"
-	"github.com/spf13/cobra"
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	"github.com/conductorone/baton-sdk/pkg/field"
+	"github.com/spf13/viper"
 )
 
-// config defines the external configuration required for the connector to run.
-type config struct {
-	cli.BaseConfig `mapstructure:",squash"` // Puts the base config options in the same place as the connector options
-	APIKey         string                   `mapstructure:"apikey"`
-}
+var (
+	apiKeyField = field.StringField(
+		"apikey",
+		field.WithRequired(true),
+		field.WithDescription("The API key used to connect to NewRelic GraphQL API."),
+	)
+	maxQPSField = field.IntField(
+		"max-qps",
+		field.WithDescription("The maximum number of requests per second sent to NewRelic GraphQL API, 0 means no limit."),
+	)
+	regionField = field.StringField(
+		"region",
+		field.WithDefaultValue(newrelic.RegionUS),
+		field.WithDescription("The datacenter region of NewRelic organization: us, eu."),
+	)
+	baseURLField = field.StringField(
+		"base-url",
+		field.WithDescription("Override the URL of NewRelic GraphQL API, takes precedence over region."),
+	)
+)
+
+// configuration defines the external configuration required for the connector to run.
+var configuration = field.NewConfiguration([]field.SchemaField{
+	apiKeyField,
+	maxQPSField,
+	regionField,
+	baseURLField,
+})
 
 // validateConfig is run after the configuration is loaded, and should return an error if it isn't valid.
-func validateConfig(ctx context.Context, cfg *config) error {
-	if cfg.APIKey == "" {
+func validateConfig(ctx context.Context, v *viper.Viper) error {
+	if v.GetString(apiKeyField.FieldName) == "" {
 		return fmt.Errorf("apikey must be provided")
 	}
 
-	return nil
-}
+	if v.GetInt(maxQPSField.FieldName) < 0 {
+		return fmt.Errorf("max-qps must not be negative")
+	}
 
-func cmdFlags(cmd *cobra.Command) {
-	cmd.PersistentFlags().String("apikey", "", "The API key used to connect to NewRelic GraphQL API. ($BATON_APIKEY)")
+	switch strings.ToLower(v.GetString(regionField.FieldName)) {
+	case "", newrelic.RegionUS, newrelic.RegionEU:
+	default:
+		return fmt.Errorf("region must be one of: %s, %s", newrelic.RegionUS, newrelic.RegionEU)
+	}
+
+	if baseURL := v.GetString(baseURLField.FieldName); baseURL != "" {
+		u, err := url.Parse(baseURL)
+		if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("base-url must be a valid http(s) URL")
+		}
+	}
+
+	return nil
 }
@@ -5,13 +5,15 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/conductorone/baton-sdk/pkg/cli"
+	configSchema "github.com/conductorone/baton-sdk/pkg/config"
 	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/types"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
 
 	"github.com/conductorone/baton-newrelic/pkg/connector"
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
 )
 
 var version = "dev"
@@ -19,15 +21,13 @@ var version = "dev"
 func main() {
 	ctx := context.Background()
 
-	cfg := &config{}
-	cmd, err := cli.NewCmd(ctx, "baton-newrelic", cfg, validateConfig, getConnector)
+	_, cmd, err := configSchema.DefineConfiguration(ctx, "baton-newrelic", getConnector, configuration)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err.Error())
 		os.Exit(1)
 	}
 
 	cmd.Version = version
-	cmdFlags(cmd)
 
 	err = cmd.Execute()
 	if err != nil {
@@ -36,10 +36,20 @@ func main() {
 	}
 }
 
-func getConnector(ctx context.Context, cfg *config) (types.ConnectorServer, error) {
+func getConnector(ctx context.Context, v *viper.Viper) (types.ConnectorServer, error) {
 	l := ctxzap.Extract(ctx)
 
-	cb, err := connector.New(ctx, cfg.APIKey)
+	if err := validateConfig(ctx, v); err != nil {
+		return nil, err
+	}
+
+	cb, err := connector.New(
+		ctx,
+		v.GetString(apiKeyField.FieldName),
+		newrelic.WithRequestsPerSecond(v.GetInt(maxQPSField.FieldName)),
+		newrelic.WithRegion(v.GetString(regionField.FieldName)),
+		newrelic.WithBaseURL(v.GetString(baseURLField.FieldName)),
+	)
 	if err != nil {
 		l.Error("error creating connector", zap.Error(err))
 		return nil, err
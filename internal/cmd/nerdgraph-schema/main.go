@@ -0,0 +1,174 @@
+// Command nerdgraph-schema introspects NerdGraph and writes the schema trimmed to types and fields
+// reachable from operations of the client, the operations are read from their golden files.
+//
+// It is run by go generate in pkg/newrelic and requires NEW_RELIC_API_KEY, NEW_RELIC_GRAPHQL_URL
+// overrides the endpoint, e.g. for the EU region:
+//
+//	NEW_RELIC_API_KEY=... go generate ./pkg/newrelic
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vektah/gqlparser/v2/ast"
+	"github.com/vektah/gqlparser/v2/parser"
+)
+
+const defaultURL = "https://api.newrelic.com/graphql"
+
+func main() {
+	operations := flag.String("operations", filepath.Join("testdata", "operations"), "directory with golden files of operations")
+	out := flag.String("out", filepath.Join("testdata", "nerdgraph.graphql"), "path of the written schema")
+	flag.Parse()
+
+	url := os.Getenv("NEW_RELIC_GRAPHQL_URL")
+	if url == "" {
+		url = defaultURL
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	if err := run(ctx, url, os.Getenv("NEW_RELIC_API_KEY"), *operations, *out, time.Now()); err != nil {
+		fmt.Fprintln(os.Stderr, "nerdgraph-schema:", err)
+		os.Exit(1)
+	}
+}
+
+// run writes schema of NerdGraph at the url trimmed to the operations, the header records the url and the date.
+func run(ctx context.Context, url, apikey, operationsDir, out string, now time.Time) error {
+	if apikey == "" {
+		return errors.New("NEW_RELIC_API_KEY has to be set to introspect NerdGraph")
+	}
+
+	docs, err := readOperations(operationsDir)
+	if err != nil {
+		return err
+	}
+
+	res, err := introspect(ctx, url, apikey)
+	if err != nil {
+		return fmt.Errorf("failed to introspect %s: %w", url, err)
+	}
+
+	trimmer := newSchemaTrimmer(res)
+	for _, doc := range docs {
+		for _, def := range doc.Operations {
+			root := res.Data.Schema.QueryType
+			if def.Operation == ast.Mutation {
+				root = res.Data.Schema.MutationType
+			}
+
+			if root == nil {
+				return fmt.Errorf("schema has no root type of %s", def.Name)
+			}
+
+			trimmer.walk(root.Name, def.SelectionSet)
+		}
+	}
+
+	header := fmt.Sprintf(`# Trimmed introspection of NerdGraph (%s) taken on %s, limited to types and fields
+# reachable from operations sent by the client. Operations in graphql.go are validated against it
+# offline by TestOperationsMatchSchema.
+#
+# Regenerate after changing operations or when NerdGraph deprecates or changes a field:
+#   NEW_RELIC_API_KEY=... go generate ./pkg/newrelic
+`, url, now.UTC().Format(time.DateOnly))
+
+	return os.WriteFile(out, []byte(header+"\n"+trimmer.sdl()), 0o600)
+}
+
+// readOperations parses golden files of operations, ordered by name so the output doesn't depend on the file system.
+func readOperations(dir string) ([]*ast.QueryDocument, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no operations found in %s", dir)
+	}
+
+	sort.Strings(paths)
+
+	docs := make([]*ast.QueryDocument, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, gqlErr := parser.ParseQuery(&ast.Source{Name: path, Input: string(data)})
+		if gqlErr != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, gqlErr)
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return docs, nil
+}
+
+// introspect sends the introspection query to NerdGraph.
+func introspect(ctx context.Context, url, apikey string) (introspectionResponse, error) {
+	var res introspectionResponse
+
+	body, err := json.Marshal(map[string]string{"query": introspectionQuery})
+	if err != nil {
+		return res, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return res, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("API-Key", apikey)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return res, fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return res, fmt.Errorf("unexpected status %s: %s", resp.Status, data)
+	}
+
+	if err := json.Unmarshal(data, &res); err != nil {
+		return res, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(res.Errors) > 0 {
+		messages := make([]string, 0, len(res.Errors))
+		for _, e := range res.Errors {
+			messages = append(messages, e.Message)
+		}
+
+		return res, errors.New(strings.Join(messages, "; "))
+	}
+
+	if len(res.Data.Schema.Types) == 0 {
+		return res, errors.New("response has no types")
+	}
+
+	return res, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// introspection of a small schema, Actor.accountId and Query.version are not used by the operation.
+const testIntrospection = `{"data": {"__schema": {
+  "queryType": {"name": "Query"},
+  "mutationType": null,
+  "types": [
+    {"kind": "OBJECT", "name": "Query", "fields": [
+      {"name": "actor", "args": [], "type": {"kind": "OBJECT", "name": "Actor"}},
+      {"name": "version", "args": [], "type": {"kind": "SCALAR", "name": "String"}}
+    ], "interfaces": []},
+    {"kind": "OBJECT", "name": "Actor", "fields": [
+      {"name": "user", "args": [
+        {"name": "id", "type": {"kind": "NON_NULL", "ofType": {"kind": "SCALAR", "name": "ID"}}}
+      ], "type": {"kind": "OBJECT", "name": "User"}},
+      {"name": "accountId", "args": [], "type": {"kind": "SCALAR", "name": "Int"}}
+    ], "interfaces": []},
+    {"kind": "OBJECT", "name": "User", "fields": [
+      {"name": "email", "args": [], "type": {"kind": "SCALAR", "name": "String"},
+       "isDeprecated": true, "deprecationReason": "use emails"},
+      {"name": "name", "args": [], "type": {"kind": "SCALAR", "name": "String"}}
+    ], "interfaces": []},
+    {"kind": "SCALAR", "name": "ID"},
+    {"kind": "SCALAR", "name": "Int"},
+    {"kind": "SCALAR", "name": "String"}
+  ]
+}}}`
+
+const wantSchema = `schema {
+  query: Query
+}
+
+type Query {
+  actor: Actor
+}
+
+type Actor {
+  user(id: ID!): User
+}
+
+type User {
+  email: String @deprecated(reason: "use emails")
+}
+`
+
+func TestRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("API-Key") != "key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte(testIntrospection))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	operations := filepath.Join(dir, "operations")
+	if err := os.Mkdir(operations, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	op := "query GetUser($userId: ID!) {\n  actor {\n    user(id: $userId) {\n      email\n    }\n  }\n}"
+	if err := os.WriteFile(filepath.Join(operations, "GetUser.graphql"), []byte(op), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	out := filepath.Join(dir, "schema.graphql")
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+
+	if err := run(ctx, srv.URL, "", operations, out, now); err == nil {
+		t.Errorf("expected missing api key to be rejected")
+	}
+
+	if err := run(ctx, srv.URL, "invalid", operations, out, now); err == nil {
+		t.Errorf("expected failed introspection to be reported")
+	}
+
+	if err := run(ctx, srv.URL, "key", operations, out, now); err != nil {
+		t.Fatalf("failed to generate schema: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header, schema, _ := strings.Cut(string(data), "\n\n")
+	if !strings.Contains(header, srv.URL) || !strings.Contains(header, "2024-05-06") {
+		t.Errorf("expected header to record %s and the date, got:\n%s", srv.URL, header)
+	}
+
+	if schema != wantSchema {
+		t.Errorf("unexpected schema:\n%s", schema)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// introspectionQuery asks for every type of the schema with deprecated fields and enum values included.
+const introspectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    types {
+      kind
+      name
+      fields(includeDeprecated: true) {
+        name
+        args { name defaultValue type { ...TypeRef } }
+        type { ...TypeRef }
+        isDeprecated
+        deprecationReason
+      }
+      inputFields { name defaultValue type { ...TypeRef } }
+      interfaces { name }
+      enumValues(includeDeprecated: true) { name isDeprecated deprecationReason }
+      possibleTypes { name }
+    }
+  }
+}
+
+fragment TypeRef on __Type {
+  kind
+  name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } }
+}`
+
+type introspectionResponse struct {
+	Data struct {
+		Schema struct {
+			QueryType    *typeName          `json:"queryType"`
+			MutationType *typeName          `json:"mutationType"`
+			Types        []introspectedType `json:"types"`
+		} `json:"__schema"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+type typeName struct {
+	Name string `json:"name"`
+}
+
+type typeRef struct {
+	Kind   string   `json:"kind"`
+	Name   string   `json:"name"`
+	OfType *typeRef `json:"ofType"`
+}
+
+// String formats the reference as SDL type, e.g. [ID!]!.
+func (r typeRef) String() string {
+	switch {
+	case r.Kind == "NON_NULL" && r.OfType != nil:
+		return r.OfType.String() + "!"
+	case r.Kind == "LIST" && r.OfType != nil:
+		return "[" + r.OfType.String() + "]"
+	default:
+		return r.Name
+	}
+}
+
+// named returns name of the type wrapped in lists and non null.
+func (r typeRef) named() string {
+	if r.OfType != nil {
+		return r.OfType.named()
+	}
+
+	return r.Name
+}
+
+type introspectedValue struct {
+	Name         string  `json:"name"`
+	DefaultValue *string `json:"defaultValue"`
+	Type         typeRef `json:"type"`
+}
+
+type introspectedField struct {
+	Name              string              `json:"name"`
+	Args              []introspectedValue `json:"args"`
+	Type              typeRef             `json:"type"`
+	IsDeprecated      bool                `json:"isDeprecated"`
+	DeprecationReason string              `json:"deprecationReason"`
+}
+
+type introspectedEnumValue struct {
+	Name              string `json:"name"`
+	IsDeprecated      bool   `json:"isDeprecated"`
+	DeprecationReason string `json:"deprecationReason"`
+}
+
+type introspectedType struct {
+	Kind          string                  `json:"kind"`
+	Name          string                  `json:"name"`
+	Fields        []introspectedField     `json:"fields"`
+	InputFields   []introspectedValue     `json:"inputFields"`
+	Interfaces    []typeName              `json:"interfaces"`
+	EnumValues    []introspectedEnumValue `json:"enumValues"`
+	PossibleTypes []typeName              `json:"possibleTypes"`
+}
+
+func (t *introspectedType) field(name string) *introspectedField {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+
+	return nil
+}
+
+// schemaTrimmer collects types and fields of the introspected schema used by operations.
+type schemaTrimmer struct {
+	query, mutation string
+	types           map[string]*introspectedType
+	kept            map[string]bool
+	fields          map[string]map[string]bool
+}
+
+func newSchemaTrimmer(res introspectionResponse) *schemaTrimmer {
+	s := &schemaTrimmer{
+		types:  make(map[string]*introspectedType),
+		kept:   make(map[string]bool),
+		fields: make(map[string]map[string]bool),
+	}
+
+	schema := res.Data.Schema
+	if schema.QueryType != nil {
+		s.query = schema.QueryType.Name
+	}
+
+	if schema.MutationType != nil {
+		s.mutation = schema.MutationType.Name
+	}
+
+	for i := range schema.Types {
+		s.types[schema.Types[i].Name] = &schema.Types[i]
+	}
+
+	return s
+}
+
+// keepType keeps the type, input types are kept with all their fields.
+func (s *schemaTrimmer) keepType(name string) {
+	t, ok := s.types[name]
+	if !ok || s.kept[name] {
+		return
+	}
+
+	s.kept[name] = true
+	for _, f := range t.InputFields {
+		s.keepType(f.Type.named())
+	}
+}
+
+// keepField keeps field of the type together with types of its arguments and result.
+func (s *schemaTrimmer) keepField(t *introspectedType, f *introspectedField) {
+	if s.fields[t.Name] == nil {
+		s.fields[t.Name] = make(map[string]bool)
+	}
+
+	s.fields[t.Name][f.Name] = true
+	for _, arg := range f.Args {
+		s.keepType(arg.Type.named())
+	}
+
+	s.keepType(f.Type.named())
+}
+
+// walk keeps fields selected on the type, fields missing in the schema are left for the validation to report.
+func (s *schemaTrimmer) walk(name string, set ast.SelectionSet) {
+	t, ok := s.types[name]
+	if !ok {
+		return
+	}
+
+	s.keepType(name)
+	for _, sel := range set {
+		switch sel := sel.(type) {
+		case *ast.Field:
+			f := t.field(sel.Name)
+			if f == nil {
+				continue
+			}
+
+			s.keepField(t, f)
+			s.walk(f.Type.named(), sel.SelectionSet)
+
+		case *ast.InlineFragment:
+			s.walk(sel.TypeCondition, sel.SelectionSet)
+		}
+	}
+
+	// objects have to declare fields of kept interfaces they implement
+	for _, i := range t.Interfaces {
+		for f := range s.fields[i.Name] {
+			if field := t.field(f); field != nil {
+				s.keepField(t, field)
+			}
+		}
+	}
+
+	if t.Kind == "INTERFACE" {
+		for _, p := range t.PossibleTypes {
+			if s.kept[p.Name] {
+				s.walk(p.Name, nil)
+			}
+		}
+	}
+}
+
+// sdl prints the kept types, root types first and the rest ordered by name.
+func (s *schemaTrimmer) sdl() string {
+	var b strings.Builder
+
+	b.WriteString("schema {\n")
+	b.WriteString("  query: " + s.query + "\n")
+	if s.mutation != "" && s.kept[s.mutation] {
+		b.WriteString("  mutation: " + s.mutation + "\n")
+	}
+	b.WriteString("}\n")
+
+	var scalars, names []string
+	for name := range s.kept {
+		switch {
+		case s.types[name].Kind == "SCALAR":
+			if !isBuiltinScalar(name) {
+				scalars = append(scalars, name)
+			}
+		case name != s.query && name != s.mutation:
+			names = append(names, name)
+		}
+	}
+	sort.Strings(scalars)
+	sort.Strings(names)
+
+	if len(scalars) > 0 {
+		b.WriteString("\n")
+		for _, name := range scalars {
+			b.WriteString("scalar " + name + "\n")
+		}
+	}
+
+	for _, name := range append([]string{s.query, s.mutation}, names...) {
+		if s.kept[name] {
+			b.WriteString("\n")
+			s.writeType(&b, s.types[name])
+		}
+	}
+
+	return b.String()
+}
+
+func (s *schemaTrimmer) writeType(b *strings.Builder, t *introspectedType) {
+	switch t.Kind {
+	case "OBJECT", "INTERFACE":
+		keyword := "type"
+		if t.Kind == "INTERFACE" {
+			keyword = "interface"
+		}
+
+		var interfaces []string
+		for _, i := range t.Interfaces {
+			if s.kept[i.Name] {
+				interfaces = append(interfaces, i.Name)
+			}
+		}
+
+		b.WriteString(keyword + " " + t.Name)
+		if len(interfaces) > 0 {
+			b.WriteString(" implements " + strings.Join(interfaces, " & "))
+		}
+		b.WriteString(" {\n")
+
+		for _, f := range t.Fields {
+			if !s.fields[t.Name][f.Name] {
+				continue
+			}
+
+			b.WriteString("  " + f.Name)
+			if len(f.Args) > 0 {
+				args := make([]string, 0, len(f.Args))
+				for _, arg := range f.Args {
+					args = append(args, formatValue(arg))
+				}
+				b.WriteString("(" + strings.Join(args, ", ") + ")")
+			}
+			b.WriteString(": " + f.Type.String() + deprecated(f.IsDeprecated, f.DeprecationReason) + "\n")
+		}
+
+		b.WriteString("}\n")
+
+	case "INPUT_OBJECT":
+		b.WriteString("input " + t.Name + " {\n")
+		for _, f := range t.InputFields {
+			b.WriteString("  " + formatValue(f) + "\n")
+		}
+		b.WriteString("}\n")
+
+	case "ENUM":
+		b.WriteString("enum " + t.Name + " {\n")
+		for _, v := range t.EnumValues {
+			b.WriteString("  " + v.Name + deprecated(v.IsDeprecated, v.DeprecationReason) + "\n")
+		}
+		b.WriteString("}\n")
+
+	case "UNION":
+		var members []string
+		for _, p := range t.PossibleTypes {
+			if s.kept[p.Name] {
+				members = append(members, p.Name)
+			}
+		}
+		b.WriteString("union " + t.Name + " = " + strings.Join(members, " | ") + "\n")
+	}
+}
+
+// formatValue formats argument or input field with its default value.
+func formatValue(v introspectedValue) string {
+	rv := v.Name + ": " + v.Type.String()
+	if v.DefaultValue != nil {
+		rv += " = " + *v.DefaultValue
+	}
+
+	return rv
+}
+
+func deprecated(isDeprecated bool, reason string) string {
+	if !isDeprecated {
+		return ""
+	}
+
+	return " @deprecated(reason: " + strconv.Quote(reason) + ")"
+}
+
+func isBuiltinScalar(name string) bool {
+	switch name {
+	case "Int", "Float", "String", "Boolean", "ID":
+		return true
+	}
+
+	return false
+}
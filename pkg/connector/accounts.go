@@ -0,0 +1,78 @@
+package connector
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type accountBuilder struct {
+	resourceType *v2.ResourceType
+	client       *newrelic.Client
+}
+
+func (a *accountBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return accountResourceType
+}
+
+func accountResource(ctx context.Context, pId *v2.ResourceId, account *newrelic.Account) (*v2.Resource, error) {
+	resource, err := rs.NewResource(
+		account.Name,
+		accountResourceType,
+		strconv.Itoa(account.ID),
+		rs.WithParentResourceID(pId),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns all the accounts under organization as resource objects.
+func (a *accountBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil {
+		return nil, "", nil, nil
+	}
+
+	accounts, err := a.client.ListAccounts(ctx)
+	if err != nil {
+		return nil, "", nil, wrapError(err, "newrelic-connector: failed to list accounts")
+	}
+
+	var rv []*v2.Resource
+	for _, account := range accounts {
+		accountCopy := account
+		ar, err := accountResource(ctx, parentResourceID, &accountCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, ar)
+	}
+
+	return rv, "", annotationsForRateLimit(a.client), nil
+}
+
+// Entitlements always returns an empty slice for accounts.
+func (a *accountBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants always returns an empty slice for accounts since they don't have any entitlements.
+func (a *accountBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func newAccountBuilder(client *newrelic.Client) *accountBuilder {
+	return &accountBuilder{
+		resourceType: accountResourceType,
+		client:       client,
+	}
+}
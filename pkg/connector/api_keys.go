@@ -0,0 +1,240 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	apiKeyOwnership = "owner"
+)
+
+type apiKeyBuilder struct {
+	resourceType *v2.ResourceType
+	client       *newrelic.Client
+}
+
+func (k *apiKeyBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return apiKeyResourceType
+}
+
+// apiKeyResource creates api key resource, parent of the key is the user owning it
+// or the account it belongs to for ingest keys. Type, account and creation time of the key are kept in the profile.
+func apiKeyResource(ctx context.Context, pId *v2.ResourceId, key *newrelic.APIKey) (*v2.Resource, error) {
+	name := key.Name
+	if name == "" {
+		name = key.ID
+	}
+
+	profile := map[string]interface{}{
+		"key_type":   key.Type,
+		"account_id": key.AccountID,
+	}
+
+	if key.IngestType != "" {
+		profile["ingest_type"] = key.IngestType
+	}
+
+	if key.CreatedAt != 0 {
+		profile["created_at"] = time.Unix(key.CreatedAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	description := fmt.Sprintf("%s key on account %d", apiKeyTypeName(key), key.AccountID)
+	if key.User != nil {
+		description = fmt.Sprintf("%s owned by %s", description, key.User.Email)
+		profile["owner_id"] = strconv.Itoa(key.User.ID)
+		profile["owner_email"] = key.User.Email
+		pId = &v2.ResourceId{
+			ResourceType: userResourceType.Id,
+			Resource:     strconv.Itoa(key.User.ID),
+		}
+	} else if key.AccountID != 0 {
+		pId = &v2.ResourceId{
+			ResourceType: accountResourceType.Id,
+			Resource:     strconv.Itoa(key.AccountID),
+		}
+	}
+
+	resource, err := rs.NewAppResource(
+		name,
+		apiKeyResourceType,
+		key.ID,
+		[]rs.AppTraitOption{
+			rs.WithAppProfile(profile),
+		},
+		rs.WithParentResourceID(pId),
+		rs.WithDescription(description),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// apiKeyTypeName returns human readable type of the key, e.g. "User" or "License ingest".
+func apiKeyTypeName(key *newrelic.APIKey) string {
+	if key.Type == newrelic.APIKeyTypeIngest && key.IngestType != "" {
+		return fmt.Sprintf("%s ingest", titleCase(key.IngestType))
+	}
+
+	return titleCase(key.Type)
+}
+
+func titleCase(value string) string {
+	if value == "" {
+		return value
+	}
+
+	return strings.ToUpper(value[:1]) + strings.ToLower(value[1:])
+}
+
+// List returns all the user and ingest keys visible to the connector as resource objects.
+func (k *apiKeyBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil {
+		return nil, "", nil, nil
+	}
+
+	// parse the token
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: apiKeyResourceType.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	keys, nextCursor, err := k.client.ListAPIKeys(ctx, bag.PageToken())
+	if err != nil {
+		return nil, "", nil, wrapError(err, "newrelic-connector: failed to list api keys")
+	}
+
+	// add next cursor to bag
+	next, err := bag.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Resource
+	for _, key := range keys {
+		keyCopy := key
+		kr, err := apiKeyResource(ctx, parentResourceID, &keyCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, kr)
+	}
+
+	return rv, next, annotationsForRateLimit(k.client), nil
+}
+
+// Entitlements returns ownership entitlement of the key.
+func (k *apiKeyBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var rv []*v2.Entitlement
+
+	permissionOptions := []ent.EntitlementOption{
+		ent.WithGrantableTo(userResourceType),
+		ent.WithDisplayName(fmt.Sprintf("%s API Key %s", resource.DisplayName, apiKeyOwnership)),
+		ent.WithDescription(fmt.Sprintf("%s of %s API key in NewRelic", apiKeyOwnership, resource.DisplayName)),
+	}
+
+	rv = append(rv, ent.NewAssignmentEntitlement(resource, apiKeyOwnership, permissionOptions...))
+
+	return rv, "", nil, nil
+}
+
+// Grants returns ownership grant of user keys, ingest keys are not owned by users.
+func (k *apiKeyBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	pId := resource.ParentResourceId
+	if pId == nil || pId.ResourceType != userResourceType.Id {
+		return nil, "", nil, nil
+	}
+
+	rv := []*v2.Grant{
+		grant.NewGrant(resource, apiKeyOwnership, pId),
+	}
+
+	return rv, "", nil, nil
+}
+
+func (k *apiKeyBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	l.Warn(
+		"newrelic-connector: api key ownership cannot be granted, keys are created by users in NewRelic",
+		zap.String("principal_id", principal.Id.String()),
+		zap.String("entitlement_id", entitlement.Id),
+	)
+
+	return nil, fmt.Errorf("newrelic-connector: api key ownership cannot be granted")
+}
+
+// Revoke deletes the user key, the owner loses access granted by the key. Key type and owner are taken
+// from the key resource, grants of users who don't own the key are reported as already revoked.
+func (k *apiKeyBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	principal := grant.Principal
+	entitlement := grant.Entitlement
+
+	if principal.Id.ResourceType != userResourceType.Id {
+		l.Warn(
+			"newrelic-connector: only users can have api key ownership revoked",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("newrelic-connector: only users can have api key ownership revoked")
+	}
+
+	key := entitlement.Resource
+	appTrait, err := rs.GetAppTrait(key)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: api key %s has no profile: %v", key.Id.Resource, err)
+	}
+
+	keyType, ok := rs.GetProfileStringValue(appTrait.Profile, "key_type")
+	if !ok || keyType == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: type of api key %s is unknown", key.Id.Resource)
+	}
+
+	ownerId, _ := rs.GetProfileStringValue(appTrait.Profile, "owner_id")
+	if ownerId != principal.Id.Resource {
+		l.Debug(
+			"newrelic-connector: user does not own the api key",
+			zap.String("api_key_id", key.Id.Resource),
+			zap.String("user_id", principal.Id.Resource),
+			zap.String("owner_id", ownerId),
+		)
+
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	err = k.client.DeleteAPIKey(ctx, key.Id.Resource, keyType)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to delete api key")
+	}
+
+	return nil, nil
+}
+
+func newAPIKeyBuilder(client *newrelic.Client) *apiKeyBuilder {
+	return &apiKeyBuilder{
+		resourceType: apiKeyResourceType,
+		client:       client,
+	}
+}
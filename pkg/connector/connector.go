@@ -2,7 +2,6 @@ package connector
 
 import (
 	"context"
-	"fmt"
 	"io"
 	"net/http"
 
@@ -20,11 +19,18 @@ type NewRelic struct {
 
 // ResourceSyncers returns a ResourceSyncer for each resource type that should be synced from the upstream service.
 func (nr *NewRelic) ResourceSyncers(ctx context.Context) []connectorbuilder.ResourceSyncer {
+	// user types are collected while listing users and emitted as grants of user tiers
+	tiers := newUserTierIndex()
+
 	return []connectorbuilder.ResourceSyncer{
 		newOrgBuilder(nr.client),
-		newUserBuilder(nr.client),
+		newAccountBuilder(nr.client),
+		newDomainBuilder(nr.client),
+		newUserBuilder(nr.client, tiers),
+		newUserTierBuilder(nr.client, tiers),
 		newGroupBuilder(nr.client),
 		newRoleBuilder(nr.client),
+		newAPIKeyBuilder(nr.client),
 	}
 }
 
@@ -38,7 +44,7 @@ func (nr *NewRelic) Asset(ctx context.Context, asset *v2.AssetRef) (string, io.R
 func (nr *NewRelic) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error) {
 	return &v2.ConnectorMetadata{
 		DisplayName: "NewRelic Connector",
-		Description: "Connector syncing NewRelic organizations, users, groups and roles to Baton",
+		Description: "Connector syncing NewRelic organizations, accounts, authentication domains, users, groups, roles and API keys to Baton",
 	}, nil
 }
 
@@ -47,14 +53,14 @@ func (nr *NewRelic) Metadata(ctx context.Context) (*v2.ConnectorMetadata, error)
 func (nr *NewRelic) Validate(ctx context.Context) (annotations.Annotations, error) {
 	_, err := nr.client.GetOrg(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("newrelic-connector: failed to retrieve org: %w", err)
+		return nil, wrapError(err, "newrelic-connector: failed to retrieve org")
 	}
 
 	return nil, nil
 }
 
-// New returns a new instance of the connector.
-func New(ctx context.Context, apikey string) (*NewRelic, error) {
+// New returns a new instance of the connector. Options are passed through to the NewRelic client.
+func New(ctx context.Context, apikey string, opts ...newrelic.Option) (*NewRelic, error) {
 	var httpClient *http.Client
 	var err error
 
@@ -65,7 +71,7 @@ func New(ctx context.Context, apikey string) (*NewRelic, error) {
 		}
 	}
 
-	nrClient, err := newrelic.NewClient(ctx, httpClient, apikey)
+	nrClient, err := newrelic.NewClient(ctx, httpClient, apikey, opts...)
 	if err != nil {
 		return nil, err
 	}
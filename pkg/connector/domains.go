@@ -0,0 +1,121 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+)
+
+type domainBuilder struct {
+	resourceType *v2.ResourceType
+	client       *newrelic.Client
+}
+
+func (d *domainBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return domainResourceType
+}
+
+func domainResource(ctx context.Context, pId *v2.ResourceId, domain *newrelic.Domain) (*v2.Resource, error) {
+	profile := map[string]interface{}{
+		"domain_id":           domain.ID,
+		"provisioning_type":   domain.ProvisioningType,
+		"authentication_type": domain.AuthenticationType,
+		"users_count":         domain.UsersTotal,
+		"groups_count":        domain.Total,
+	}
+
+	resource, err := rs.NewGroupResource(
+		domain.Name,
+		domainResourceType,
+		domain.ID,
+		[]rs.GroupTraitOption{
+			rs.WithGroupProfile(profile),
+		},
+		rs.WithParentResourceID(pId),
+		rs.WithDescription(
+			fmt.Sprintf(
+				"Authentication domain with %s provisioning and %s authentication",
+				domain.ProvisioningType,
+				domain.AuthenticationType,
+			),
+		),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// domainResourceId returns id of the domain resource, used as parent of groups and users within the domain.
+func domainResourceId(domainId string) *v2.ResourceId {
+	return &v2.ResourceId{
+		ResourceType: domainResourceType.Id,
+		Resource:     domainId,
+	}
+}
+
+// List returns all the authentication domains from the organization as resource objects.
+func (d *domainBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil {
+		return nil, "", nil, nil
+	}
+
+	// start of the sync, domains cached by the client in previous syncs could be outdated
+	if pToken.Token == "" {
+		d.client.InvalidateDomains()
+	}
+
+	// parse the token
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: domainResourceType.Id})
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	domains, nextCursor, err := d.client.ListDomains(ctx, bag.PageToken())
+	if err != nil {
+		return nil, "", nil, wrapError(err, "newrelic-connector: failed to list domains")
+	}
+
+	// add next cursor to bag
+	next, err := bag.NextToken(nextCursor)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Resource
+	for _, domain := range domains {
+		domainCopy := domain
+		dr, err := domainResource(ctx, parentResourceID, &domainCopy)
+		if err != nil {
+			return nil, "", nil, err
+		}
+
+		rv = append(rv, dr)
+	}
+
+	return rv, next, annotationsForRateLimit(d.client), nil
+}
+
+// Entitlements always returns an empty slice for domains.
+func (d *domainBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+// Grants always returns an empty slice for domains since they don't have any entitlements.
+func (d *domainBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	return nil, "", nil, nil
+}
+
+func newDomainBuilder(client *newrelic.Client) *domainBuilder {
+	return &domainBuilder{
+		resourceType: domainResourceType,
+		client:       client,
+	}
+}
@@ -0,0 +1,352 @@
+package connector
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	defaultEventsPageSize = 100
+	// maxEventsPageSize is the highest LIMIT accepted by NRQL.
+	maxEventsPageSize = 5000
+	// defaultEventsLookback is used when the feed is read for the first time without earliest event.
+	defaultEventsLookback = 24 * time.Hour
+)
+
+// NrAuditEvent action identifiers converted into events.
+var (
+	loginActions       = []string{"user.login", "user_management.login"}
+	createUserActions  = []string{"user.create", "user_management.create_user"}
+	addMemberActions   = []string{"user_management.add_users_to_groups", "user_management.group.add_users"}
+	removeMemberAction = []string{"user_management.remove_users_from_groups", "user_management.group.remove_users"}
+	grantRoleActions   = []string{"authorization_management.grant_access"}
+	revokeRoleActions  = []string{"authorization_management.revoke_access"}
+)
+
+// eventsCursor holds timestamp of the latest event returned for each account and ids of the events
+// of that millisecond, the next page starts at the same millisecond and skips them. Accounts not read
+// yet keep their progress, so a page which ran out of events before reaching them continues with them.
+type eventsCursor struct {
+	Accounts map[string]int64    `json:"accounts"`
+	Seen     map[string][]string `json:"seen,omitempty"`
+}
+
+func parseEventsCursor(cursor string) (*eventsCursor, error) {
+	c := &eventsCursor{
+		Accounts: make(map[string]int64),
+		Seen:     make(map[string][]string),
+	}
+	if cursor == "" {
+		return c, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("newrelic-connector: invalid events cursor: %w", err)
+	}
+
+	if err := json.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("newrelic-connector: invalid events cursor: %w", err)
+	}
+
+	if c.Accounts == nil {
+		c.Accounts = make(map[string]int64)
+	}
+
+	if c.Seen == nil {
+		c.Seen = make(map[string][]string)
+	}
+
+	return c, nil
+}
+
+func (c *eventsCursor) marshal() (string, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// ListEvents returns usage, grant and revoke events converted from NrAuditEvent of every account,
+// each account is read from the millisecond of the latest event returned for it skipping events already returned.
+// The page size bounds audit events read across all accounts, accounts are read in turn until it is used up.
+func (nr *NewRelic) ListEvents(
+	ctx context.Context,
+	earliestEvent *timestamppb.Timestamp,
+	pToken *pagination.StreamToken,
+) ([]*v2.Event, *pagination.StreamState, annotations.Annotations, error) {
+	cursor, err := parseEventsCursor(pToken.Cursor)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	limit := pToken.Size
+	if limit <= 0 {
+		limit = defaultEventsPageSize
+	}
+
+	if limit > maxEventsPageSize {
+		limit = maxEventsPageSize
+	}
+
+	start := time.Now().Add(-defaultEventsLookback)
+	if earliestEvent != nil {
+		start = earliestEvent.AsTime()
+	}
+
+	accounts, err := nr.client.ListAccounts(ctx)
+	if err != nil {
+		return nil, nil, nil, wrapError(err, "newrelic-connector: failed to list accounts")
+	}
+
+	roles := newRoleNames(nr.client)
+
+	var rv []*v2.Event
+	hasMore := false
+	remaining := limit
+	for _, account := range accounts {
+		if remaining <= 0 {
+			hasMore = true
+			break
+		}
+
+		accountId := strconv.Itoa(account.ID)
+
+		since, ok := cursor.Accounts[accountId]
+		if !ok {
+			since = start.UnixMilli()
+		}
+
+		// events already returned at the boundary millisecond come back first, they are not counted to the limit
+		seen := cursor.Seen[accountId]
+		if len(seen) >= maxEventsPageSize {
+			// more events of the millisecond than NRQL returns at once, the rest of them can't be reached
+			ctxzap.Extract(ctx).Warn(
+				"newrelic-connector: too many audit events in the same millisecond, skipping the rest of them",
+				zap.String("account_id", accountId),
+				zap.Int64("timestamp", since),
+			)
+			since, seen = since+1, nil
+		}
+
+		pageSize := min(remaining+len(seen), maxEventsPageSize)
+
+		auditEvents, err := nr.client.ListAuditEvents(ctx, account.ID, since, pageSize)
+		if err != nil {
+			return nil, nil, nil, wrapError(err, "newrelic-connector: failed to list audit events")
+		}
+
+		for _, ae := range auditEvents {
+			if ae.Timestamp == since && slices.Contains(seen, ae.ID) {
+				continue
+			}
+
+			// events of the same millisecond come in any order, new ones may precede those already returned
+			if remaining <= 0 {
+				break
+			}
+
+			if ae.Timestamp > since {
+				since = ae.Timestamp
+				seen = nil
+			}
+			seen = append(seen, ae.ID)
+			remaining--
+
+			event, err := convertAuditEvent(ctx, roles, &ae)
+			if err != nil {
+				return nil, nil, nil, err
+			}
+
+			if event != nil {
+				rv = append(rv, event)
+			}
+		}
+
+		cursor.Accounts[accountId] = since
+		cursor.Seen[accountId] = seen
+		if len(auditEvents) >= pageSize {
+			hasMore = true
+		}
+	}
+
+	next, err := cursor.marshal()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return rv, &pagination.StreamState{Cursor: next, HasMore: hasMore}, annotationsForRateLimit(nr.client), nil
+}
+
+// convertAuditEvent converts audit event into normalized event, returns nil for events which are not converted.
+func convertAuditEvent(ctx context.Context, roles *roleNames, ae *newrelic.AuditEvent) (*v2.Event, error) {
+	l := ctxzap.Extract(ctx)
+
+	event := &v2.Event{
+		Id:         ae.ID,
+		OccurredAt: timestamppb.New(time.UnixMilli(ae.Timestamp)),
+	}
+
+	action := strings.ToLower(ae.ActionIdentifier)
+	switch {
+	case slices.Contains(loginActions, action):
+		if ae.ActorID == "" {
+			return nil, nil
+		}
+
+		event.Event = &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				ActorResource:  eventResource(userResourceType, ae.ActorID),
+				TargetResource: eventResource(accountResourceType, strconv.Itoa(ae.AccountID)),
+			},
+		}
+
+	case slices.Contains(createUserActions, action):
+		if ae.ActorID == "" || ae.TargetID == "" {
+			return nil, nil
+		}
+
+		event.Event = &v2.Event_UsageEvent{
+			UsageEvent: &v2.UsageEvent{
+				ActorResource:  eventResource(userResourceType, ae.ActorID),
+				TargetResource: eventResource(userResourceType, ae.TargetID),
+			},
+		}
+
+	case slices.Contains(addMemberActions, action), slices.Contains(removeMemberAction, action):
+		groupId, userId := eventTarget(ae, groupResourceType.Id, "groupId"), eventTarget(ae, userResourceType.Id, "userId")
+		if groupId == "" || userId == "" {
+			l.Debug("newrelic-connector: skipping group membership event without group or user", zap.String("event_id", ae.ID))
+			return nil, nil
+		}
+
+		group := eventResource(groupResourceType, groupId)
+		user := eventResource(userResourceType, userId)
+		if slices.Contains(addMemberActions, action) {
+			event.Event = &v2.Event_GrantEvent{
+				GrantEvent: &v2.GrantEvent{Grant: grant.NewGrant(group, groupMembership, user.Id)},
+			}
+		} else {
+			event.Event = &v2.Event_RevokeEvent{
+				RevokeEvent: &v2.RevokeEvent{
+					Entitlement: ent.NewAssignmentEntitlement(group, groupMembership),
+					Principal:   user,
+				},
+			}
+		}
+
+	case slices.Contains(grantRoleActions, action), slices.Contains(revokeRoleActions, action):
+		groupId, roleId := eventTarget(ae, groupResourceType.Id, "groupId"), ae.Attribute("roleId")
+		if groupId == "" || roleId == "" {
+			l.Debug("newrelic-connector: skipping role event without group or role", zap.String("event_id", ae.ID))
+			return nil, nil
+		}
+
+		roleName, err := roles.get(ctx, roleId)
+		if err != nil {
+			return nil, err
+		}
+
+		if roleName == "" {
+			l.Debug("newrelic-connector: skipping role event of unknown role", zap.String("event_id", ae.ID), zap.String("role_id", roleId))
+			return nil, nil
+		}
+
+		slug := roleName
+		if accountId, err := strconv.Atoi(ae.Attribute("accountId")); err == nil && accountId != 0 {
+			slug = accountEntitlementSlug(roleName, accountId)
+		}
+
+		role := eventResource(roleResourceType, roleId)
+		group := eventResource(groupResourceType, groupId)
+		if slices.Contains(grantRoleActions, action) {
+			event.Event = &v2.Event_GrantEvent{
+				GrantEvent: &v2.GrantEvent{Grant: grant.NewGrant(role, slug, group.Id)},
+			}
+		} else {
+			event.Event = &v2.Event_RevokeEvent{
+				RevokeEvent: &v2.RevokeEvent{
+					Entitlement: ent.NewAssignmentEntitlement(role, slug),
+					Principal:   group,
+				},
+			}
+		}
+
+	default:
+		return nil, nil
+	}
+
+	return event, nil
+}
+
+// eventTarget returns id of the resource of the type from target of the event or from the attribute.
+func eventTarget(ae *newrelic.AuditEvent, resourceType, attribute string) string {
+	if strings.EqualFold(ae.TargetType, resourceType) {
+		return ae.TargetID
+	}
+
+	return ae.Attribute(attribute)
+}
+
+func eventResource(resourceType *v2.ResourceType, id string) *v2.Resource {
+	return &v2.Resource{
+		Id: &v2.ResourceId{
+			ResourceType: resourceType.Id,
+			Resource:     id,
+		},
+	}
+}
+
+// roleNames lazily maps role ids to role names used in entitlement ids, roles are listed only if needed.
+type roleNames struct {
+	client *newrelic.Client
+	names  map[string]string
+}
+
+func newRoleNames(client *newrelic.Client) *roleNames {
+	return &roleNames{client: client}
+}
+
+func (r *roleNames) get(ctx context.Context, roleId string) (string, error) {
+	if r.names == nil {
+		r.names = make(map[string]string)
+
+		cursor := ""
+		for {
+			roles, nextCursor, err := r.client.ListRoles(ctx, cursor)
+			if err != nil {
+				return "", wrapError(err, "newrelic-connector: failed to list roles")
+			}
+
+			for _, role := range roles {
+				r.names[role.ID] = role.Name
+			}
+
+			if nextCursor == "" {
+				break
+			}
+
+			cursor = nextCursor
+		}
+	}
+
+	return r.names[roleId], nil
+}
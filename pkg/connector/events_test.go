@@ -0,0 +1,327 @@
+package connector_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic/newrelictest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// eventsStart is the earliest event requested by the tests, audit events are recorded after it.
+var eventsStart = time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+// describeEvent formats the event as "<kind> <entitlement or target> <principal or actor>".
+func describeEvent(e *v2.Event) string {
+	id := func(r *v2.Resource) string {
+		return r.GetId().GetResourceType() + ":" + r.GetId().GetResource()
+	}
+
+	switch {
+	case e.GetUsageEvent() != nil:
+		return fmt.Sprintf("usage %s %s", id(e.GetUsageEvent().TargetResource), id(e.GetUsageEvent().ActorResource))
+	case e.GetGrantEvent() != nil:
+		g := e.GetGrantEvent().Grant
+		return fmt.Sprintf("grant %s %s", g.Entitlement.Id, id(g.Principal))
+	case e.GetRevokeEvent() != nil:
+		return fmt.Sprintf("revoke %s %s", e.GetRevokeEvent().Entitlement.Id, id(e.GetRevokeEvent().Principal))
+	default:
+		return "unknown"
+	}
+}
+
+func TestConvertAuditEvent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	for _, tc := range []struct {
+		name       string
+		attributes map[string]interface{}
+		want       string
+	}{
+		{
+			name:       "login",
+			attributes: map[string]interface{}{"actionIdentifier": "user.login", "actorId": "1"},
+			want:       "usage account:100 user:1",
+		},
+		{
+			name:       "user management login",
+			attributes: map[string]interface{}{"actionIdentifier": "user_management.login", "actorId": "1"},
+			want:       "usage account:100 user:1",
+		},
+		{
+			name:       "login without actor",
+			attributes: map[string]interface{}{"actionIdentifier": "user.login"},
+		},
+		{
+			name:       "user created",
+			attributes: map[string]interface{}{"actionIdentifier": "user.create", "actorId": "1", "targetId": "3"},
+			want:       "usage user:3 user:1",
+		},
+		{
+			name:       "user created by user management",
+			attributes: map[string]interface{}{"actionIdentifier": "user_management.create_user", "actorId": "1", "targetId": "3"},
+			want:       "usage user:3 user:1",
+		},
+		{
+			name: "users added to groups",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "user_management.add_users_to_groups", "targetType": "group", "targetId": "g3", "userId": "3",
+			},
+			want: "grant group:g3:member user:3",
+		},
+		{
+			name: "group users added",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "user_management.group.add_users", "targetType": "user", "targetId": "3", "groupId": "g3",
+			},
+			want: "grant group:g3:member user:3",
+		},
+		{
+			name: "users removed from groups",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "user_management.remove_users_from_groups", "targetType": "group", "targetId": "g1", "userId": "2",
+			},
+			want: "revoke group:g1:member user:2",
+		},
+		{
+			name: "group users removed",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "user_management.group.remove_users", "targetType": "user", "targetId": "2", "groupId": "g1",
+			},
+			want: "revoke group:g1:member user:2",
+		},
+		{
+			name:       "membership change without user",
+			attributes: map[string]interface{}{"actionIdentifier": "user_management.group.add_users", "groupId": "g1"},
+		},
+		{
+			name: "account role granted",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "authorization_management.grant_access", "targetType": "group", "targetId": "g3", "roleId": "10", "accountId": 100,
+			},
+			want: "grant role:10:all_product_admin:100 group:g3",
+		},
+		{
+			name: "organization role granted",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "authorization_management.grant_access", "groupId": "g3", "roleId": "20",
+			},
+			want: "grant role:20:organization_manager group:g3",
+		},
+		{
+			name: "account role revoked",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "authorization_management.revoke_access", "targetType": "group", "targetId": "g1", "roleId": "10", "accountId": 200,
+			},
+			want: "revoke role:10:all_product_admin:200 group:g1",
+		},
+		{
+			name: "unknown role",
+			attributes: map[string]interface{}{
+				"actionIdentifier": "authorization_management.grant_access", "groupId": "g3", "roleId": "99",
+			},
+		},
+		{
+			name:       "action which is not converted",
+			attributes: map[string]interface{}{"actionIdentifier": "alerts.policy.create", "actorId": "1"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newrelictest.NewServer()
+			defer fake.Close()
+			seedOrg(fake)
+
+			tc.attributes["id"] = "e1"
+			tc.attributes["timestamp"] = float64(eventsStart.Add(time.Minute).UnixMilli())
+			fake.AddAuditEvent(100, tc.attributes)
+
+			client := serveConnector(t, ctx, fake)
+
+			resp, err := client.ListEvents(ctx, &v2.ListEventsRequest{StartAt: timestamppb.New(eventsStart)})
+			if err != nil {
+				t.Fatalf("failed to list events: %v", err)
+			}
+
+			var got []string
+			for _, e := range resp.Events {
+				got = append(got, describeEvent(e))
+
+				if e.Id != "e1" || !e.OccurredAt.AsTime().Equal(eventsStart.Add(time.Minute)) {
+					t.Errorf("expected event e1 at %s, got %s at %s", eventsStart.Add(time.Minute), e.Id, e.OccurredAt.AsTime())
+				}
+			}
+
+			if tc.want == "" {
+				if len(got) != 0 {
+					t.Errorf("expected no events, got %v", got)
+				}
+				return
+			}
+
+			if len(got) != 1 || got[0] != tc.want {
+				t.Errorf("expected event %q, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestListEventsSameMillisecond(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	// three events of the same millisecond don't fit into a page of two
+	boundary := eventsStart.Add(time.Minute).UnixMilli()
+	for i, ts := range []int64{boundary, boundary, boundary, boundary + 1} {
+		fake.AddAuditEvent(100, map[string]interface{}{
+			"id":               fmt.Sprintf("e%d", i+1),
+			"timestamp":        float64(ts),
+			"actionIdentifier": "user.login",
+			"actorId":          "1",
+		})
+	}
+
+	client := serveConnector(t, ctx, fake)
+
+	seen := make(map[string]int)
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > 5 {
+			t.Fatalf("expected events to be read in a few pages, got %v", seen)
+		}
+
+		resp, err := client.ListEvents(ctx, &v2.ListEventsRequest{
+			Cursor:   cursor,
+			StartAt:  timestamppb.New(eventsStart),
+			PageSize: 2,
+		})
+		if err != nil {
+			t.Fatalf("failed to list events: %v", err)
+		}
+
+		for _, e := range resp.Events {
+			seen[e.Id]++
+		}
+
+		if !resp.HasMore {
+			break
+		}
+
+		cursor = resp.Cursor
+	}
+
+	for _, id := range []string{"e1", "e2", "e3", "e4"} {
+		if seen[id] != 1 {
+			t.Errorf("expected event %s to be listed once, got %d", id, seen[id])
+		}
+	}
+}
+
+func TestListEventsAcrossAccounts(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	for i := 0; i < 6; i++ {
+		accountId := 100
+		if i%2 == 1 {
+			accountId = 200
+		}
+
+		fake.AddAuditEvent(accountId, map[string]interface{}{
+			"id":               fmt.Sprintf("e%d", i+1),
+			"timestamp":        float64(eventsStart.Add(time.Duration(i+1) * time.Minute).UnixMilli()),
+			"actionIdentifier": "user.login",
+			"actorId":          "1",
+		})
+	}
+
+	client := serveConnector(t, ctx, fake)
+
+	seen := make(map[string]int)
+	cursor := ""
+	for page := 0; ; page++ {
+		if page > 10 {
+			t.Fatalf("expected events to be read in a few pages, got %v", seen)
+		}
+
+		resp, err := client.ListEvents(ctx, &v2.ListEventsRequest{
+			Cursor:   cursor,
+			StartAt:  timestamppb.New(eventsStart),
+			PageSize: 2,
+		})
+		if err != nil {
+			t.Fatalf("failed to list events: %v", err)
+		}
+
+		// page size bounds events of all accounts together, not events of each account
+		if len(resp.Events) > 2 {
+			t.Errorf("expected at most 2 events in a page, got %d", len(resp.Events))
+		}
+
+		for _, e := range resp.Events {
+			seen[e.Id]++
+		}
+
+		if !resp.HasMore {
+			break
+		}
+
+		cursor = resp.Cursor
+	}
+
+	for i := 1; i <= 6; i++ {
+		if id := fmt.Sprintf("e%d", i); seen[id] != 1 {
+			t.Errorf("expected event %s to be listed once, got %d", id, seen[id])
+		}
+	}
+}
+
+func TestListEventsLimitAfterSameMillisecond(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	for _, id := range []string{"e1", "e2"} {
+		fake.AddAuditEvent(100, map[string]interface{}{
+			"id":               id,
+			"timestamp":        float64(eventsStart.Add(time.Minute).UnixMilli()),
+			"actionIdentifier": "user.login",
+			"actorId":          "1",
+		})
+	}
+
+	client := serveConnector(t, ctx, fake)
+
+	first, err := client.ListEvents(ctx, &v2.ListEventsRequest{StartAt: timestamppb.New(eventsStart), PageSize: 1})
+	if err != nil {
+		t.Fatalf("failed to list events: %v", err)
+	}
+
+	// events already returned at the boundary millisecond must not push the query over the NRQL limit
+	second, err := client.ListEvents(ctx, &v2.ListEventsRequest{
+		Cursor:   first.Cursor,
+		StartAt:  timestamppb.New(eventsStart),
+		PageSize: 5000,
+	})
+	if err != nil {
+		t.Fatalf("failed to list events with the largest page: %v", err)
+	}
+
+	if len(first.Events) != 1 || len(second.Events) != 1 || first.Events[0].Id == second.Events[0].Id {
+		t.Errorf("expected e1 and e2 in separate pages, got %d and %d events", len(first.Events), len(second.Events))
+	}
+}
@@ -0,0 +1,205 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	// groupMembersBatchSize is the number of groups fetched in a single request.
+	groupMembersBatchSize = 25
+	// groupMembersWorkers is the number of requests for group members sent concurrently.
+	groupMembersWorkers = 4
+)
+
+// membersPageKey identifies page of members of the group, empty cursor is the first page.
+type membersPageKey struct {
+	groupId string
+	cursor  string
+}
+
+func (g *groupBuilder) resetMembers() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.pending = make(map[string][]membersPageKey)
+	g.members = make(map[membersPageKey]newrelic.GroupMembers)
+}
+
+// addPending records listed groups of the domain, so their members can be fetched together.
+func (g *groupBuilder) addPending(domainId string, groupIds []string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.pending == nil {
+		g.pending = make(map[string][]membersPageKey)
+	}
+
+	for _, id := range groupIds {
+		g.pending[domainId] = append(g.pending[domainId], membersPageKey{groupId: id})
+	}
+}
+
+// takePending returns the page followed by pending pages of other groups of the domain not fetched yet,
+// removing them from pending. Each group is included at most once, as pages are fetched under alias of the group.
+func (g *groupBuilder) takePending(domainId string, key membersPageKey, limit int) []membersPageKey {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	rv := []membersPageKey{key}
+	taken := map[string]bool{key.groupId: true}
+
+	var rest []membersPageKey
+	for _, k := range g.pending[domainId] {
+		if k == key {
+			continue
+		}
+
+		if _, ok := g.members[k]; ok {
+			continue
+		}
+
+		if len(rv) < limit && !taken[k.groupId] {
+			rv = append(rv, k)
+			taken[k.groupId] = true
+		} else {
+			rest = append(rest, k)
+		}
+	}
+
+	g.pending[domainId] = rest
+
+	return rv
+}
+
+// popMembers returns prefetched page of members, the page is returned only once.
+func (g *groupBuilder) popMembers(key membersPageKey) (*newrelic.GroupMembers, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	members, ok := g.members[key]
+	if !ok {
+		return nil, false
+	}
+
+	delete(g.members, key)
+
+	return &members, true
+}
+
+// membersPage returns page of members of the group. If the page was not prefetched, it is fetched together
+// with pending pages of other groups of the domain, first pages of listed groups and following pages of groups
+// fetched before, in batches by bounded pool of workers. Missing group fails only its own page.
+func (g *groupBuilder) membersPage(ctx context.Context, domainId, groupId, cursor string) (*newrelic.GroupMembers, error) {
+	key := membersPageKey{groupId: groupId, cursor: cursor}
+
+	members, ok := g.popMembers(key)
+	if !ok {
+		fetched, err := g.fetchMembers(ctx, domainId, g.takePending(domainId, key, groupMembersBatchSize*groupMembersWorkers))
+		if err != nil {
+			return nil, err
+		}
+
+		members, ok = fetched[key]
+		if !ok {
+			return nil, fmt.Errorf("%w: members of group %s", newrelic.ErrNotFound, groupId)
+		}
+	}
+
+	if members.Err != nil {
+		return nil, members.Err
+	}
+
+	return members, nil
+}
+
+// fetchMembers fetches the pages in batches, pages other than the first one are kept for later
+// and following pages are recorded as pending.
+func (g *groupBuilder) fetchMembers(ctx context.Context, domainId string, keys []membersPageKey) (map[membersPageKey]*newrelic.GroupMembers, error) {
+	var mu sync.Mutex
+	fetched := make(map[membersPageKey]*newrelic.GroupMembers, len(keys))
+
+	eg, egCtx := errgroup.WithContext(ctx)
+	eg.SetLimit(groupMembersWorkers)
+	for start := 0; start < len(keys); start += groupMembersBatchSize {
+		batch := keys[start:min(start+groupMembersBatchSize, len(keys))]
+
+		eg.Go(func() error {
+			groupIds := make([]string, 0, len(batch))
+			cursors := make(map[string]string, len(batch))
+			for _, k := range batch {
+				groupIds = append(groupIds, k.groupId)
+				cursors[k.groupId] = k.cursor
+			}
+
+			pages, err := g.client.ListGroupsMembers(egCtx, domainId, groupIds, cursors)
+			if err != nil {
+				return err
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			for i := range pages {
+				p := pages[i]
+				fetched[membersPageKey{groupId: p.GroupID, cursor: cursors[p.GroupID]}] = &p
+			}
+
+			return nil
+		})
+	}
+
+	if err := eg.Wait(); err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.members == nil {
+		g.members = make(map[membersPageKey]newrelic.GroupMembers)
+	}
+
+	if g.pending == nil {
+		g.pending = make(map[string][]membersPageKey)
+	}
+
+	for k, p := range fetched {
+		if p.Err == nil && p.NextCursor != "" {
+			g.pending[domainId] = append(g.pending[domainId], membersPageKey{groupId: k.groupId, cursor: p.NextCursor})
+		}
+
+		if k != keys[0] {
+			g.members[k] = *p
+		}
+	}
+
+	return fetched, nil
+}
+
+// forgetMembers drops prefetched members and pending following pages of the group after its membership changed.
+func (g *groupBuilder) forgetMembers(groupId string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for k := range g.members {
+		if k.groupId == groupId {
+			delete(g.members, k)
+		}
+	}
+
+	for domainId, keys := range g.pending {
+		var rest []membersPageKey
+		for _, k := range keys {
+			if k.groupId != groupId || k.cursor == "" {
+				rest = append(rest, k)
+			}
+		}
+
+		g.pending[domainId] = rest
+	}
+}
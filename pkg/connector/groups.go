@@ -3,7 +3,7 @@ package connector
 import (
 	"context"
 	"fmt"
-	"strings"
+	"sync"
 
 	"github.com/conductorone/baton-newrelic/pkg/newrelic"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -14,6 +14,8 @@ import (
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const (
@@ -23,13 +25,20 @@ const (
 type groupBuilder struct {
 	resourceType *v2.ResourceType
 	client       *newrelic.Client
+
+	// pages of members waiting to be fetched during the current sync, keyed by domain id,
+	// and pages of members already fetched in batches but not returned yet
+	mu      sync.Mutex
+	pending map[string][]membersPageKey
+	members map[membersPageKey]newrelic.GroupMembers
 }
 
 func (g *groupBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 	return groupResourceType
 }
 
-func groupResource(ctx context.Context, parentId *v2.ResourceId, domainId string, group *newrelic.Group) (*v2.Resource, error) {
+// groupResource creates group resource, parent of the group is the authentication domain it belongs to.
+func groupResource(ctx context.Context, domainId string, group *newrelic.Group) (*v2.Resource, error) {
 	profile := map[string]interface{}{
 		"group_domain": domainId,
 	}
@@ -41,7 +50,7 @@ func groupResource(ctx context.Context, parentId *v2.ResourceId, domainId string
 		[]rs.GroupTraitOption{
 			rs.WithGroupProfile(profile),
 		},
-		rs.WithParentResourceID(parentId),
+		rs.WithParentResourceID(domainResourceId(domainId)),
 	)
 
 	if err != nil {
@@ -58,18 +67,23 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 		return nil, "", nil, nil
 	}
 
+	// start of the pagination, forget members fetched in previous syncs
+	if pToken.Token == "" {
+		g.resetMembers()
+	}
+
 	// parse the token
-	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: domainResourceType})
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: domainResourceType.Id})
 	if err != nil {
 		return nil, "", nil, err
 	}
 
 	switch bag.ResourceTypeID() {
-	case domainResourceType:
+	case domainResourceType.Id:
 		// list and paginate through domains
 		domains, nextDomainsCursor, err := g.client.ListDomains(ctx, bag.PageToken())
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", nil, wrapError(err, "newrelic-connector: failed to list domains")
 		}
 
 		// remove old cursors from bag
@@ -79,7 +93,7 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 		if nextDomainsCursor != "" {
 			bag.Push(
 				pagination.PageState{
-					ResourceTypeID: domainResourceType,
+					ResourceTypeID: domainResourceType.Id,
 					Token:          nextDomainsCursor,
 				},
 			)
@@ -90,48 +104,44 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 				continue
 			}
 
+			token, err := pageState{DomainID: d.ID}.encode()
+			if err != nil {
+				return nil, "", nil, err
+			}
+
 			// add cursors for paginating groups under this domain
 			bag.Push(
 				pagination.PageState{
 					ResourceTypeID: groupResourceType.Id,
-					Token:          fmt.Sprintf("%s:", d.ID),
+					Token:          token,
 				},
 			)
 		}
 
-		// if there are no more cursors, return nil
-		var token string
-		if bag.Current() != nil {
-			token = bag.PageToken()
-		}
-
-		// handle next iteration
-		next, err := bag.NextToken(token)
+		// handle next iteration, empty token if there are no more cursors
+		next, err := bag.Marshal()
 		if err != nil {
-			if err.Error() != "no active page state" {
-				return nil, "", nil, err
-			}
+			return nil, "", nil, err
 		}
 
-		return nil, next, nil, nil
+		return nil, next, annotationsForRateLimit(g.client), nil
 
 	case groupResourceType.Id:
 		// list and paginate through groups within a domain
-		parts := strings.Split(bag.PageToken(), ":")
-		if len(parts) != 2 {
-			return nil, "", nil, fmt.Errorf("invalid page token: %s (type: %s)", bag.PageToken(), bag.ResourceTypeID())
+		state, err := decodeDomainPageState(bag.PageToken())
+		if err != nil {
+			return nil, "", nil, err
 		}
 
-		domainId := parts[0]
-		cursor := parts[1]
+		domainId := state.DomainID
 
-		// list all groups within all domains with specific role
-		groups, nextGroupsCursor, err := g.client.ListGroups(ctx, domainId, cursor)
+		// list groups within the domain
+		groups, nextGroupsCursor, err := g.client.ListGroups(ctx, domainId, state.Cursor)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, "", nil, wrapError(err, "newrelic-connector: failed to list groups")
 		}
 
-		c, err := composeCursor(domainId, nextGroupsCursor)
+		c, err := state.next(nextGroupsCursor)
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -142,25 +152,29 @@ func (g *groupBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId
 		}
 
 		var rv []*v2.Resource
-		for _, g := range groups {
-			groupCopy := g
+		var groupIds []string
+		for _, group := range groups {
+			groupCopy := group
 
-			gr, err := groupResource(ctx, parentResourceID, domainId, &groupCopy)
+			gr, err := groupResource(ctx, domainId, &groupCopy)
 			if err != nil {
 				return nil, "", nil, err
 			}
 
 			rv = append(rv, gr)
+			groupIds = append(groupIds, group.ID)
 		}
 
-		return rv, next, nil, nil
+		g.addPending(domainId, groupIds)
+
+		return rv, next, annotationsForRateLimit(g.client), nil
 
 	default:
 		return nil, "", nil, fmt.Errorf("invalid resource type: %s", bag.ResourceTypeID())
 	}
 }
 
-// Entitlements always returns an empty slice for groups.
+// Entitlements returns membership entitlement of the group, grantable to users.
 func (g *groupBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var rv []*v2.Entitlement
 
@@ -175,7 +189,8 @@ func (g *groupBuilder) Entitlements(_ context.Context, resource *v2.Resource, _
 	return rv, "", nil, nil
 }
 
-// Grants always returns an empty slice for groups since they don't have any entitlements.
+// Grants returns membership grants of the group members, members of groups of the same domain
+// are fetched together in batched requests.
 func (g *groupBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
 	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: userResourceType.Id})
 	if err != nil {
@@ -193,18 +208,19 @@ func (g *groupBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken
 		return nil, "", nil, fmt.Errorf("unable to get domain id from group trait profile")
 	}
 
-	members, nextDomainsCursor, err := g.client.ListGroupMembers(ctx, domainId, resource.Id.Resource, bag.PageToken())
+	// pages of members are fetched in batches with other groups of the domain
+	members, err := g.membersPage(ctx, domainId, resource.Id.Resource, bag.PageToken())
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, wrapError(err, "newrelic-connector: failed to list group members")
 	}
 
-	next, err := bag.NextToken(nextDomainsCursor)
+	next, err := bag.NextToken(members.NextCursor)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
 	var rv []*v2.Grant
-	for _, uId := range members {
+	for _, uId := range members.Members {
 		rv = append(rv, grant.NewGrant(
 			resource,
 			groupMembership,
@@ -215,7 +231,7 @@ func (g *groupBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken
 		))
 	}
 
-	return rv, next, nil, nil
+	return rv, next, annotationsForRateLimit(g.client), nil
 }
 
 func (g *groupBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
@@ -232,11 +248,28 @@ func (g *groupBuilder) Grant(ctx context.Context, principal *v2.Resource, entitl
 	}
 
 	groupId, userId := entitlement.Resource.Id.Resource, principal.Id.Resource
-	err := g.client.AddUserToGroup(ctx, groupId, userId)
+	member, known, err := g.isMember(ctx, entitlement.Resource, userId)
 	if err != nil {
-		return nil, fmt.Errorf("newrelic-connector: failed to add user to group: %w", err)
+		return nil, err
 	}
 
+	if known && member {
+		l.Debug(
+			"newrelic-connector: user is already a member of the group",
+			zap.String("group_id", groupId),
+			zap.String("user_id", userId),
+		)
+
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	err = g.client.AddUserToGroup(ctx, groupId, userId)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to add user to group")
+	}
+
+	g.forgetMembers(groupId)
+
 	return nil, nil
 }
 
@@ -257,14 +290,105 @@ func (g *groupBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations
 	}
 
 	groupId, userId := entitlement.Resource.Id.Resource, principal.Id.Resource
-	err := g.client.RemoveUserFromGroup(ctx, groupId, userId)
+	member, known, err := g.isMember(ctx, entitlement.Resource, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if known && !member {
+		l.Debug(
+			"newrelic-connector: user is not a member of the group",
+			zap.String("group_id", groupId),
+			zap.String("user_id", userId),
+		)
+
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	err = g.client.RemoveUserFromGroup(ctx, groupId, userId)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to remove user from group")
+	}
+
+	g.forgetMembers(groupId)
+
+	return nil, nil
+}
+
+// isMember reports whether the user is a member of the group. Membership is known only for groups
+// with authentication domain in the parent or the profile, as members are listed under the domain.
+func (g *groupBuilder) isMember(ctx context.Context, group *v2.Resource, userId string) (bool, bool, error) {
+	domainId, err := groupDomain(group)
 	if err != nil {
-		return nil, fmt.Errorf("newrelic-connector: failed to remove user from group: %w", err)
+		return false, false, nil
+	}
+
+	member, err := g.client.IsGroupMember(ctx, domainId, group.Id.Resource, userId)
+	if err != nil {
+		return false, false, wrapError(err, "newrelic-connector: failed to check group membership")
+	}
+
+	return member, true, nil
+}
+
+// Create creates a new group within the authentication domain set as the parent resource.
+// Only new groups are created, resource with an id of existing group is rejected rather than changing the group,
+// groups are not renamed as there is no operation for updating resources.
+func (g *groupBuilder) Create(ctx context.Context, resource *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	if resource.Id != nil && resource.Id.Resource != "" {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: group %s already exists, only new groups can be created", resource.Id.Resource)
+	}
+
+	if resource.DisplayName == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "newrelic-connector: group name is required")
+	}
+
+	domainId, err := groupDomain(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	group, err := g.client.CreateGroup(ctx, domainId, resource.DisplayName)
+	if err != nil {
+		return nil, nil, wrapError(err, "newrelic-connector: failed to create group")
+	}
+
+	gr, err := groupResource(ctx, domainId, group)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return gr, nil, nil
+}
+
+func (g *groupBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	if resourceId.ResourceType != groupResourceType.Id {
+		return nil, fmt.Errorf("newrelic-connector: invalid resource type: %s", resourceId.ResourceType)
+	}
+
+	err := g.client.DeleteGroup(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to delete group")
 	}
 
 	return nil, nil
 }
 
+// groupDomain returns authentication domain of the group from the parent resource or the group profile.
+func groupDomain(resource *v2.Resource) (string, error) {
+	if pId := resource.ParentResourceId; pId != nil && pId.ResourceType == domainResourceType.Id && pId.Resource != "" {
+		return pId.Resource, nil
+	}
+
+	if groupTrait, err := rs.GetGroupTrait(resource); err == nil {
+		if domainId, ok := rs.GetProfileStringValue(groupTrait.Profile, "group_domain"); ok && domainId != "" {
+			return domainId, nil
+		}
+	}
+
+	return "", status.Error(codes.InvalidArgument, "newrelic-connector: authentication domain is required as the parent of the group")
+}
+
 func newGroupBuilder(client *newrelic.Client) *groupBuilder {
 	return &groupBuilder{
 		resourceType: groupResourceType,
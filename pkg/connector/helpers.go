@@ -1,11 +1,16 @@
 package connector
 
 import (
+	"errors"
 	"fmt"
 
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 const ResourcesPageSize uint = 50
@@ -16,6 +21,27 @@ func annotationsForUserResourceType() annotations.Annotations {
 	return annos
 }
 
+// annotationsForRateLimit reports rate limiting observed by the client back to the sync engine.
+func annotationsForRateLimit(client *newrelic.Client) annotations.Annotations {
+	state := client.RateLimit()
+	description := &v2.RateLimitDescription{
+		Status: v2.RateLimitDescription_STATUS_OK,
+		Limit:  state.Limit,
+	}
+
+	if state.Throttled {
+		description.Status = v2.RateLimitDescription_STATUS_OVERLIMIT
+	}
+
+	if !state.ResetAt.IsZero() {
+		description.ResetAt = timestamppb.New(state.ResetAt)
+	}
+
+	annos := annotations.Annotations{}
+	annos.Update(description)
+	return annos
+}
+
 func parsePageToken(i string, resourceID *v2.ResourceId) (*pagination.Bag, error) {
 	b := &pagination.Bag{}
 	err := b.Unmarshal(i)
@@ -33,18 +59,21 @@ func parsePageToken(i string, resourceID *v2.ResourceId) (*pagination.Bag, error
 	return b, nil
 }
 
-func composeCursor(domainId, groupC string) (string, error) {
-	if domainId == "" && groupC == "" {
-		return "", fmt.Errorf("domainId and groupCursor cannot both be empty")
-	}
-
-	if domainId == "" {
-		return "", fmt.Errorf("domainId cannot be empty")
-	}
-
-	if groupC == "" {
-		return "", nil
+// wrapError annotates error returned by NewRelic client with gRPC status code matching its type.
+func wrapError(err error, message string) error {
+	var code codes.Code
+	switch {
+	case errors.Is(err, newrelic.ErrNotFound):
+		code = codes.NotFound
+	case errors.Is(err, newrelic.ErrForbidden):
+		code = codes.PermissionDenied
+	case errors.Is(err, newrelic.ErrValidation):
+		code = codes.InvalidArgument
+	case errors.Is(err, newrelic.ErrThrottled):
+		code = codes.Unavailable
+	default:
+		return fmt.Errorf("%s: %w", message, err)
 	}
 
-	return fmt.Sprintf("%s:%s", domainId, groupC), nil
+	return status.Errorf(code, "%s: %v", message, err)
 }
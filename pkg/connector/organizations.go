@@ -25,9 +25,13 @@ func orgResource(ctx context.Context, org *newrelic.Org) (*v2.Resource, error) {
 		orgResourceType,
 		org.ID,
 		rs.WithAnnotation(
+			&v2.ChildResourceType{ResourceTypeId: accountResourceType.Id},
+			&v2.ChildResourceType{ResourceTypeId: apiKeyResourceType.Id},
+			&v2.ChildResourceType{ResourceTypeId: domainResourceType.Id},
 			&v2.ChildResourceType{ResourceTypeId: groupResourceType.Id},
 			&v2.ChildResourceType{ResourceTypeId: roleResourceType.Id},
 			&v2.ChildResourceType{ResourceTypeId: userResourceType.Id},
+			&v2.ChildResourceType{ResourceTypeId: userTierResourceType.Id},
 		),
 	)
 
@@ -43,7 +47,7 @@ func orgResource(ctx context.Context, org *newrelic.Org) (*v2.Resource, error) {
 func (o *orgBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
 	org, err := o.client.GetOrg(ctx)
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, wrapError(err, "newrelic-connector: failed to get org")
 	}
 
 	var rv []*v2.Resource
@@ -55,7 +59,7 @@ func (o *orgBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 
 	rv = append(rv, or)
 
-	return rv, "", nil, nil
+	return rv, "", annotationsForRateLimit(o.client), nil
 }
 
 // Entitlements always returns an empty slice for orgs.
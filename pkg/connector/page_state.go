@@ -0,0 +1,81 @@
+package connector
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// pageStateVersion is the version of encoded page state, bumped on incompatible changes of pageState.
+const pageStateVersion = 1
+
+// pageState is the pagination state of resources nested in a domain,
+// kept encoded in page tokens of the pagination bag.
+//
+// Only the domain and the cursor within it are carried. Users and groups are the only resources
+// paginated through domains, members of a group are paginated with the group taken from the resource
+// being listed and events keep progress of each account in their own cursor, so no group or account
+// id is needed here. Adding an optional field is compatible with tokens of the current version, while
+// renaming, removing or changing meaning of a field requires bumping pageStateVersion, so tokens of
+// a sync started by the previous release are rejected rather than misread.
+type pageState struct {
+	Version  int    `json:"v"`
+	DomainID string `json:"domain_id,omitempty"`
+	Cursor   string `json:"cursor,omitempty"`
+}
+
+// encode returns the page state as base64 encoded JSON.
+func (s pageState) encode() (string, error) {
+	s.Version = pageStateVersion
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", fmt.Errorf("newrelic-connector: failed to encode page state: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// next returns encoded page state for the next page, empty if there is no next page.
+func (s pageState) next(cursor string) (string, error) {
+	if cursor == "" {
+		return "", nil
+	}
+
+	s.Cursor = cursor
+
+	return s.encode()
+}
+
+// decodePageState parses page state from the token, the state has to be of the current version.
+func decodePageState(token string) (*pageState, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("newrelic-connector: invalid page token %q: %w", token, err)
+	}
+
+	var s pageState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("newrelic-connector: invalid page token %q: %w", token, err)
+	}
+
+	if s.Version != pageStateVersion {
+		return nil, fmt.Errorf("newrelic-connector: unsupported page token version %d (expected %d)", s.Version, pageStateVersion)
+	}
+
+	return &s, nil
+}
+
+// decodeDomainPageState parses page state of resources listed within a domain.
+func decodeDomainPageState(token string) (*pageState, error) {
+	s, err := decodePageState(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.DomainID == "" {
+		return nil, fmt.Errorf("newrelic-connector: invalid page token %q: missing domain", token)
+	}
+
+	return s, nil
+}
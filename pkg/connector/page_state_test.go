@@ -0,0 +1,52 @@
+package connector
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+)
+
+func TestPageStateRoundTrip(t *testing.T) {
+	token, err := pageState{DomainID: "d1"}.next("cursor:2")
+	if err != nil {
+		t.Fatalf("failed to encode page state: %v", err)
+	}
+
+	s, err := decodeDomainPageState(token)
+	if err != nil {
+		t.Fatalf("failed to decode page state: %v", err)
+	}
+
+	if s.Version != pageStateVersion || s.DomainID != "d1" || s.Cursor != "cursor:2" {
+		t.Errorf("expected page state of d1 at cursor:2, got %+v", s)
+	}
+
+	if token, err := (pageState{DomainID: "d1"}).next(""); err != nil || token != "" {
+		t.Errorf("expected no token without next cursor, got %q %v", token, err)
+	}
+}
+
+func TestDecodePageStateErrors(t *testing.T) {
+	encode := func(data string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(data))
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{name: "not base64", token: "not a token!", err: "invalid page token"},
+		{name: "not json", token: encode("domain d1"), err: "invalid page token"},
+		{name: "unknown version", token: encode(`{"v":2,"domain_id":"d1"}`), err: "unsupported page token version 2"},
+		{name: "missing version", token: encode(`{"domain_id":"d1"}`), err: "unsupported page token version 0"},
+		{name: "missing domain", token: encode(`{"v":1,"cursor":"cursor:2"}`), err: "missing domain"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := decodeDomainPageState(tc.token)
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("expected error containing %q, got %v", tc.err, err)
+			}
+		})
+	}
+}
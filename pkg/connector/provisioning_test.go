@@ -0,0 +1,481 @@
+package connector_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	"github.com/conductorone/baton-newrelic/pkg/newrelic/newrelictest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func testResources(t *testing.T) (user, group, role *v2.Resource) {
+	t.Helper()
+
+	domainId := &v2.ResourceId{ResourceType: "domain", Resource: "d1"}
+
+	user, err := rs.NewUserResource("Carol", &v2.ResourceType{Id: "user"}, "3", nil, rs.WithParentResourceID(domainId))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	group, err = rs.NewGroupResource("Support", &v2.ResourceType{Id: "group"}, "g3", nil, rs.WithParentResourceID(domainId))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	role, err = rs.NewRoleResource("All Product Admin", &v2.ResourceType{Id: "role"}, "10", []rs.RoleTraitOption{
+		rs.WithRoleProfile(map[string]interface{}{
+			"role_scope": "account",
+			"role_name":  "all_product_admin",
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return user, group, role
+}
+
+func hasAnnotation(annos []*anypb.Any, msg proto.Message) bool {
+	a := annotations.Annotations(annos)
+	return a.Contains(msg)
+}
+
+func TestGrantsAreIdempotent(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	client := serveConnector(t, ctx, fake)
+	user, group, role := testResources(t)
+
+	membership := ent.NewAssignmentEntitlement(group, "member")
+	access := ent.NewAssignmentEntitlement(role, "all_product_admin:100")
+
+	for _, tc := range []struct {
+		name        string
+		principal   *v2.Resource
+		entitlement *v2.Entitlement
+		mutations   []string
+		granted     func() bool
+	}{
+		{
+			name:        "group membership",
+			principal:   user,
+			entitlement: membership,
+			mutations:   []string{"AddGroupMember", "RemoveGroupMember"},
+			granted: func() bool {
+				members := fake.Members("g3")
+				return len(members) == 1 && members[0] == "3"
+			},
+		},
+		{
+			name:        "account role",
+			principal:   group,
+			entitlement: access,
+			mutations:   []string{"AddAccountRole", "RemoveAccountRole"},
+			granted: func() bool {
+				grants := fake.RoleGrants("g3")
+				return len(grants) == 1 && grants[0].RoleID == "10" && grants[0].AccountID == 100
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				resp, err := client.Grant(ctx, &v2.GrantManagerServiceGrantRequest{
+					Principal:   tc.principal,
+					Entitlement: tc.entitlement,
+				})
+				if err != nil {
+					t.Fatalf("grant %d failed: %v", i, err)
+				}
+
+				if exists := hasAnnotation(resp.Annotations, &v2.GrantAlreadyExists{}); exists != (i > 0) {
+					t.Errorf("grant %d: expected already exists annotation to be %v", i, i > 0)
+				}
+			}
+
+			if !tc.granted() {
+				t.Errorf("expected entitlement to be granted")
+			}
+
+			if n := fake.Requests(tc.mutations[0]); n != 1 {
+				t.Errorf("expected single %s request, got %d", tc.mutations[0], n)
+			}
+
+			g := grant.NewGrant(tc.entitlement.Resource, tc.entitlement.Slug, tc.principal.Id)
+			g.Entitlement = tc.entitlement
+			g.Principal = tc.principal
+
+			for i := 0; i < 2; i++ {
+				resp, err := client.Revoke(ctx, &v2.GrantManagerServiceRevokeRequest{Grant: g})
+				if err != nil {
+					t.Fatalf("revoke %d failed: %v", i, err)
+				}
+
+				if revoked := hasAnnotation(resp.Annotations, &v2.GrantAlreadyRevoked{}); revoked != (i > 0) {
+					t.Errorf("revoke %d: expected already revoked annotation to be %v", i, i > 0)
+				}
+			}
+
+			if tc.granted() {
+				t.Errorf("expected entitlement to be revoked")
+			}
+
+			if n := fake.Requests(tc.mutations[1]); n != 1 {
+				t.Errorf("expected single %s request, got %d", tc.mutations[1], n)
+			}
+		})
+	}
+}
+
+func TestRevokeUserTier(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	domainId := &v2.ResourceId{ResourceType: "domain", Resource: "d1"}
+	tier, err := rs.NewResource("User Tier", &v2.ResourceType{Id: "user_tier"}, "user_tier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		userId   string
+		revoked  string
+		want     string
+		noChange bool
+	}{
+		{name: "core revoked from core user", userId: "2", revoked: "core", want: newrelic.UserTierBasic},
+		{name: "core revoked from full platform user", userId: "1", revoked: "core", want: newrelic.UserTierFull, noChange: true},
+		{name: "full platform revoked from core user", userId: "2", revoked: "full_platform", want: newrelic.UserTierCore, noChange: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newrelictest.NewServer()
+			defer fake.Close()
+			seedOrg(fake)
+
+			client := serveConnector(t, ctx, fake)
+
+			user, err := rs.NewUserResource("User", &v2.ResourceType{Id: "user"}, tc.userId, nil, rs.WithParentResourceID(domainId))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			entitlement := ent.NewAssignmentEntitlement(tier, tc.revoked)
+			g := grant.NewGrant(tier, tc.revoked, user.Id)
+			g.Entitlement = entitlement
+			g.Principal = user
+
+			resp, err := client.Revoke(ctx, &v2.GrantManagerServiceRevokeRequest{Grant: g})
+			if err != nil {
+				t.Fatalf("revoke failed: %v", err)
+			}
+
+			if revoked := hasAnnotation(resp.Annotations, &v2.GrantAlreadyRevoked{}); revoked != tc.noChange {
+				t.Errorf("expected already revoked annotation to be %v", tc.noChange)
+			}
+
+			if u, _ := fake.User(tc.userId); u.Tier != tc.want {
+				t.Errorf("expected user tier %s, got %s", tc.want, u.Tier)
+			}
+
+			if n := fake.Requests("UpdateUserType"); (n == 0) != tc.noChange {
+				t.Errorf("expected user type to be updated: %v, got %d requests", !tc.noChange, n)
+			}
+		})
+	}
+}
+
+func TestGrantUserTier(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	domainId := &v2.ResourceId{ResourceType: "domain", Resource: "d1"}
+	tier, err := rs.NewResource("User Tier", &v2.ResourceType{Id: "user_tier"}, "user_tier")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		userId   string
+		granted  string
+		want     string
+		noChange bool
+	}{
+		{name: "full platform granted to core user", userId: "2", granted: "full_platform", want: newrelic.UserTierFull},
+		{name: "core granted to core user", userId: "2", granted: "core", want: newrelic.UserTierCore, noChange: true},
+		{name: "full platform granted to full platform user", userId: "1", granted: "full_platform", want: newrelic.UserTierFull, noChange: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newrelictest.NewServer()
+			defer fake.Close()
+			seedOrg(fake)
+
+			client := serveConnector(t, ctx, fake)
+
+			user, err := rs.NewUserResource("User", &v2.ResourceType{Id: "user"}, tc.userId, nil, rs.WithParentResourceID(domainId))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.Grant(ctx, &v2.GrantManagerServiceGrantRequest{
+				Principal:   user,
+				Entitlement: ent.NewAssignmentEntitlement(tier, tc.granted),
+			})
+			if err != nil {
+				t.Fatalf("grant failed: %v", err)
+			}
+
+			if exists := hasAnnotation(resp.Annotations, &v2.GrantAlreadyExists{}); exists != tc.noChange {
+				t.Errorf("expected already exists annotation to be %v", tc.noChange)
+			}
+
+			if u, _ := fake.User(tc.userId); u.Tier != tc.want {
+				t.Errorf("expected user tier %s, got %s", tc.want, u.Tier)
+			}
+
+			if n := fake.Requests("UpdateUserType"); (n == 0) != tc.noChange {
+				t.Errorf("expected user type to be updated: %v, got %d requests", !tc.noChange, n)
+			}
+		})
+	}
+}
+
+func TestCreateUserEntryPoints(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	profile := map[string]interface{}{"user_type": "core", "domain_id": "d2"}
+
+	for _, tc := range []struct {
+		name   string
+		create func(client types.ConnectorClient) (*v2.Resource, error)
+	}{
+		{
+			name: "create account",
+			create: func(client types.ConnectorClient) (*v2.Resource, error) {
+				p, err := structpb.NewStruct(profile)
+				if err != nil {
+					return nil, err
+				}
+
+				resp, err := client.CreateAccount(ctx, &v2.CreateAccountRequest{
+					AccountInfo: &v2.AccountInfo{
+						Emails:  []*v2.AccountInfo_Email{{Address: "frank@example.com", IsPrimary: true}},
+						Profile: p,
+					},
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return resp.GetSuccess().GetResource(), nil
+			},
+		},
+		{
+			name: "create resource",
+			create: func(client types.ConnectorClient) (*v2.Resource, error) {
+				user, err := rs.NewUserResource("frank@example.com", &v2.ResourceType{Id: "user"}, "", []rs.UserTraitOption{
+					rs.WithEmail("frank@example.com", true),
+					rs.WithUserProfile(profile),
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				resp, err := client.CreateResource(ctx, &v2.CreateResourceRequest{Resource: user})
+				if err != nil {
+					return nil, err
+				}
+
+				return resp.GetCreated(), nil
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newrelictest.NewServer()
+			defer fake.Close()
+			seedOrg(fake)
+
+			client := serveConnector(t, ctx, fake)
+
+			created, err := tc.create(client)
+			if err != nil {
+				t.Fatalf("failed to create user: %v", err)
+			}
+
+			u, ok := fake.User(created.GetId().GetResource())
+			if !ok {
+				t.Fatalf("expected user %v to be created", created.GetId())
+			}
+
+			if u.Email != "frank@example.com" || u.DomainID != "d2" || u.Tier != newrelic.UserTierCore {
+				t.Errorf("expected core user frank@example.com in d2, got %+v", u)
+			}
+		})
+	}
+}
+
+func TestCreateResourceOnlyCreates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	client := serveConnector(t, ctx, fake)
+	domainId := &v2.ResourceId{ResourceType: "domain", Resource: "d1"}
+
+	for _, tc := range []struct {
+		name     string
+		resource func(id string) (*v2.Resource, error)
+		created  func(id string) bool
+		existing string
+		// unchanged reports whether the existing object kept its name
+		unchanged func() bool
+	}{
+		{
+			name: "group",
+			resource: func(id string) (*v2.Resource, error) {
+				return rs.NewGroupResource("Platform", &v2.ResourceType{Id: "group"}, id, nil, rs.WithParentResourceID(domainId))
+			},
+			created: func(id string) bool {
+				g, ok := fake.Group(id)
+				return ok && g.Name == "Platform" && g.DomainID == "d1"
+			},
+			existing: "g3",
+			unchanged: func() bool {
+				g, _ := fake.Group("g3")
+				return g.Name == "Support"
+			},
+		},
+		{
+			name: "custom role",
+			resource: func(id string) (*v2.Resource, error) {
+				return rs.NewRoleResource("Auditor", &v2.ResourceType{Id: "role"}, id, []rs.RoleTraitOption{
+					rs.WithRoleProfile(map[string]interface{}{
+						"role_scope":     "organization",
+						"permission_ids": []interface{}{"1"},
+					}),
+				})
+			},
+			created: func(id string) bool {
+				r, ok := fake.Role(id)
+				return ok && r.Name == "Auditor" && len(r.PermissionIDs) == 1 && r.PermissionIDs[0] == 1
+			},
+			existing: "10",
+			unchanged: func() bool {
+				r, _ := fake.Role("10")
+				return r.Name == "all_product_admin"
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := tc.resource("")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			resp, err := client.CreateResource(ctx, &v2.CreateResourceRequest{Resource: r})
+			if err != nil {
+				t.Fatalf("failed to create %s: %v", tc.name, err)
+			}
+
+			if !tc.created(resp.GetCreated().GetId().GetResource()) {
+				t.Errorf("expected %s to be created", tc.name)
+			}
+
+			// resource of existing object must not change it
+			r, err = tc.resource(tc.existing)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, err = client.CreateResource(ctx, &v2.CreateResourceRequest{Resource: r})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Errorf("expected invalid argument for existing %s, got %v", tc.name, err)
+			}
+
+			if !tc.unchanged() {
+				t.Errorf("expected existing %s %s to be unchanged", tc.name, tc.existing)
+			}
+		})
+	}
+}
+
+func TestRevokeAPIKeyOwnership(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	key, err := rs.NewAppResource("Alice key", &v2.ResourceType{Id: "api_key"}, "k1", []rs.AppTraitOption{
+		rs.WithAppProfile(map[string]interface{}{
+			"key_type":   newrelic.APIKeyTypeUser,
+			"account_id": 100,
+			"owner_id":   "1",
+		}),
+	}, rs.WithParentResourceID(&v2.ResourceId{ResourceType: "user", Resource: "1"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		userId   string
+		noChange bool
+	}{
+		{name: "user who does not own the key", userId: "2", noChange: true},
+		{name: "owner of the key", userId: "1"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			fake := newrelictest.NewServer()
+			defer fake.Close()
+			seedOrg(fake)
+
+			client := serveConnector(t, ctx, fake)
+
+			user, err := rs.NewUserResource("User", &v2.ResourceType{Id: "user"}, tc.userId, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g := grant.NewGrant(key, "owner", user.Id)
+			g.Entitlement = ent.NewAssignmentEntitlement(key, "owner")
+			g.Principal = user
+
+			resp, err := client.Revoke(ctx, &v2.GrantManagerServiceRevokeRequest{Grant: g})
+			if err != nil {
+				t.Fatalf("revoke failed: %v", err)
+			}
+
+			if revoked := hasAnnotation(resp.Annotations, &v2.GrantAlreadyRevoked{}); revoked != tc.noChange {
+				t.Errorf("expected already revoked annotation to be %v", tc.noChange)
+			}
+
+			if _, ok := fake.APIKey("k1"); ok != tc.noChange {
+				t.Errorf("expected key to be kept: %v", tc.noChange)
+			}
+
+			if n := fake.Requests("DeleteAPIKeys"); (n == 0) != tc.noChange {
+				t.Errorf("expected key to be deleted: %v, got %d requests", !tc.noChange, n)
+			}
+		})
+	}
+}
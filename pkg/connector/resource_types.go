@@ -18,6 +18,12 @@ var (
 		DisplayName: "Org",
 		Annotations: annotationsForUserResourceType(),
 	}
+	// The account resource type is for all accounts under organization.
+	accountResourceType = &v2.ResourceType{
+		Id:          "account",
+		DisplayName: "Account",
+		Annotations: annotationsForUserResourceType(),
+	}
 	// The role resource type is for all role objects across organization.
 	roleResourceType = &v2.ResourceType{
 		Id:          "role",
@@ -30,6 +36,22 @@ var (
 		DisplayName: "Group",
 		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
 	}
+	// The user tier resource type is for user types (Basic, Core, Full Platform) users are billed for.
+	userTierResourceType = &v2.ResourceType{
+		Id:          "user_tier",
+		DisplayName: "User Tier",
+	}
 	// The domain resource type is for all authentication domain objects across organization.
-	domainResourceType = "domain"
+	domainResourceType = &v2.ResourceType{
+		Id:          "domain",
+		DisplayName: "Authentication Domain",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_GROUP},
+		Annotations: annotationsForUserResourceType(),
+	}
+	// The api key resource type is for user and ingest keys, owned by users or accounts.
+	apiKeyResourceType = &v2.ResourceType{
+		Id:          "api_key",
+		DisplayName: "API Key",
+		Traits:      []v2.ResourceType_Trait{v2.ResourceType_TRAIT_APP},
+	}
 )
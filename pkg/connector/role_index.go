@@ -0,0 +1,135 @@
+package connector
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+)
+
+// groupRoleGrant is an access grant of a role to the group.
+type groupRoleGrant struct {
+	newrelic.RoleGrant
+	groupId string
+}
+
+// groupRoleGrants returns grants of the role to groups. On first call, groups of all domains are listed
+// with grants of all roles into the index, so each group is fetched once per sync instead of once per role.
+func (r *roleBuilder) groupRoleGrants(ctx context.Context, roleId string) ([]groupRoleGrant, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.index == nil {
+		index, err := buildRoleIndex(ctx, r.client)
+		if err != nil {
+			return nil, err
+		}
+
+		r.index = index
+	}
+
+	return r.index[roleId], nil
+}
+
+// invalidateIndex drops the index, it is built again on next call of groupRoleGrants.
+func (r *roleBuilder) invalidateIndex() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.index = nil
+}
+
+// listAccounts returns accounts the account scoped roles can be granted on. Accounts are listed on first call
+// and shared by entitlements of all account scoped roles, instead of being listed once per role.
+func (r *roleBuilder) listAccounts(ctx context.Context) ([]newrelic.Account, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.accounts == nil {
+		accounts, err := r.client.ListAccounts(ctx)
+		if err != nil {
+			return nil, wrapError(err, "newrelic-connector: failed to list accounts")
+		}
+
+		// keep empty list non-nil, so organization without accounts is not listed again
+		r.accounts = append([]newrelic.Account{}, accounts...)
+	}
+
+	return r.accounts, nil
+}
+
+// invalidateAccounts drops the listed accounts, they are listed again on next call of listAccounts.
+func (r *roleBuilder) invalidateAccounts() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.accounts = nil
+}
+
+// buildRoleIndex lists grants of all roles to groups across all domains, keyed by role id.
+func buildRoleIndex(ctx context.Context, client *newrelic.Client) (map[string][]groupRoleGrant, error) {
+	index := make(map[string][]groupRoleGrant)
+
+	domainsCursor := ""
+	for {
+		domains, nextDomainsCursor, err := client.ListDomains(ctx, domainsCursor)
+		if err != nil {
+			return nil, wrapError(err, "newrelic-connector: failed to list domains")
+		}
+
+		for _, d := range domains {
+			if d.Total == 0 {
+				continue
+			}
+
+			err := indexDomainGroups(ctx, client, d.ID, index)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if nextDomainsCursor == "" {
+			return index, nil
+		}
+
+		domainsCursor = nextDomainsCursor
+	}
+}
+
+// indexDomainGroups adds grants of all roles to groups of the domain into the index.
+func indexDomainGroups(ctx context.Context, client *newrelic.Client, domainId string, index map[string][]groupRoleGrant) error {
+	groupsCursor := ""
+	for {
+		groups, nextGroupsCursor, err := client.ListGroupsWithRole(ctx, domainId, "", groupsCursor)
+		if err != nil {
+			return wrapError(err, "newrelic-connector: failed to list groups with roles")
+		}
+
+		for _, g := range groups {
+			roleGrants := g.Roles.Roles
+
+			// fetch remaining access grants of the group
+			roleCursor := g.Roles.NextCursor
+			for roleCursor != "" {
+				var moreGrants []newrelic.RoleGrant
+				moreGrants, roleCursor, err = client.ListGroupRoleGrants(ctx, domainId, g.ID, "", roleCursor)
+				if err != nil {
+					return wrapError(err, "newrelic-connector: failed to list group role grants")
+				}
+
+				roleGrants = append(roleGrants, moreGrants...)
+			}
+
+			for _, rg := range roleGrants {
+				roleId := strconv.Itoa(rg.RoleID)
+				index[roleId] = append(index[roleId], groupRoleGrant{RoleGrant: rg, groupId: g.ID})
+			}
+		}
+
+		if nextGroupsCursor == "" {
+			return nil
+		}
+
+		groupsCursor = nextGroupsCursor
+	}
+}
@@ -3,7 +3,9 @@ package connector
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/conductorone/baton-newrelic/pkg/newrelic"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
@@ -14,25 +16,46 @@ import (
 	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
 	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
 	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 const (
 	roleMembership = "member"
+	// rolePermissionsBatchSize is the number of roles whose permissions are fetched in a single request.
+	rolePermissionsBatchSize = 25
 )
 
 type roleBuilder struct {
 	resourceType *v2.ResourceType
 	client       *newrelic.Client
+
+	// grants of all roles to groups keyed by role id and accounts of account scoped roles, built once per sync
+	mu       sync.Mutex
+	index    map[string][]groupRoleGrant
+	accounts []newrelic.Account
 }
 
 func (r *roleBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 	return roleResourceType
 }
 
-func roleResource(ctx context.Context, pId *v2.ResourceId, role *newrelic.Role) (*v2.Resource, error) {
+// roleResource creates role resource, permissions granted by the role are kept in the profile.
+func roleResource(ctx context.Context, pId *v2.ResourceId, role *newrelic.Role, permissions []newrelic.Permission) (*v2.Resource, error) {
+	permissionIds := make([]interface{}, 0, len(permissions))
+	permissionNames := make([]interface{}, 0, len(permissions))
+	for _, p := range permissions {
+		permissionIds = append(permissionIds, p.ID)
+		permissionNames = append(permissionNames, p.Name)
+	}
+
 	profile := map[string]interface{}{
-		"role_scope": role.Scope,
-		"role_name":  role.Name,
+		"role_scope":     role.Scope,
+		"role_name":      role.Name,
+		"role_type":      role.Type,
+		"permission_ids": permissionIds,
+		"permissions":    permissionNames,
 	}
 
 	resource, err := rs.NewRoleResource(
@@ -59,6 +82,12 @@ func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 		return nil, "", nil, nil
 	}
 
+	// start of the pagination, role grants and accounts could change since previous sync
+	if pToken.Token == "" {
+		r.invalidateIndex()
+		r.invalidateAccounts()
+	}
+
 	// parse the token
 	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: roleResourceType.Id})
 	if err != nil {
@@ -67,7 +96,7 @@ func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 
 	roles, nextCursor, err := r.client.ListRoles(ctx, bag.PageToken())
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", nil, wrapError(err, "newrelic-connector: failed to list roles")
 	}
 
 	// add next cursor to bag
@@ -76,10 +105,21 @@ func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 		return nil, "", nil, err
 	}
 
+	roleIds := make([]string, 0, len(roles))
+	for _, role := range roles {
+		roleIds = append(roleIds, role.ID)
+	}
+
+	permissions, err := r.listRolesPermissions(ctx, roleIds)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
 	var rv []*v2.Resource
 	for _, role := range roles {
 		roleCopy := role
-		rr, err := roleResource(ctx, parentResourceID, &roleCopy)
+
+		rr, err := roleResource(ctx, parentResourceID, &roleCopy, permissions[role.ID])
 		if err != nil {
 			return nil, "", nil, err
 		}
@@ -87,11 +127,42 @@ func (r *roleBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId,
 		rv = append(rv, rr)
 	}
 
-	return rv, next, nil, nil
+	return rv, next, annotationsForRateLimit(r.client), nil
+}
+
+// listRolesPermissions returns all permissions granted by each of the roles keyed by role id. Permissions of
+// the roles are fetched together in batches, roles with more permissions than fit a page are fetched again
+// with their next cursor until all pages are read.
+func (r *roleBuilder) listRolesPermissions(ctx context.Context, roleIds []string) (map[string][]newrelic.Permission, error) {
+	rv := make(map[string][]newrelic.Permission, len(roleIds))
+
+	pending := append([]string(nil), roleIds...)
+	cursors := make(map[string]string)
+	for len(pending) > 0 {
+		batch := pending[:min(rolePermissionsBatchSize, len(pending))]
+		pending = pending[len(batch):]
+
+		pages, err := r.client.ListRolesPermissions(ctx, batch, cursors)
+		if err != nil {
+			return nil, wrapError(err, "newrelic-connector: failed to list role permissions")
+		}
+
+		for _, p := range pages {
+			rv[p.RoleID] = append(rv[p.RoleID], p.Permissions...)
+
+			if p.NextCursor != "" {
+				cursors[p.RoleID] = p.NextCursor
+				pending = append(pending, p.RoleID)
+			}
+		}
+	}
+
+	return rv, nil
 }
 
-// Entitlements always returns an empty slice for roles.
-func (r *roleBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+// Entitlements returns single entitlement for organization and group scoped roles
+// and entitlement per account for account scoped roles.
+func (r *roleBuilder) Entitlements(ctx context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
 	var rv []*v2.Entitlement
 
 	rolesTrait, err := rs.GetRoleTrait(resource)
@@ -111,132 +182,106 @@ func (r *roleBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *
 		return nil, "", nil, fmt.Errorf("unable to get role name from role trait profile")
 	}
 
-	permissionOptions := []ent.EntitlementOption{
-		ent.WithGrantableTo(groupResourceType),
-		ent.WithDisplayName(fmt.Sprintf("%s - %s Role", resource.DisplayName, roleScope)),
-		ent.WithDescription(fmt.Sprintf("%s access to %s role in NewRelic", roleMembership, resource.DisplayName)),
-	}
+	if roleScope != accScope {
+		permissionOptions := []ent.EntitlementOption{
+			ent.WithGrantableTo(groupResourceType),
+			ent.WithDisplayName(fmt.Sprintf("%s - %s Role", resource.DisplayName, roleScope)),
+			ent.WithDescription(fmt.Sprintf("%s access to %s role in NewRelic", roleMembership, resource.DisplayName)),
+		}
 
-	rv = append(rv, ent.NewAssignmentEntitlement(resource, roleName, permissionOptions...))
+		rv = append(rv, ent.NewAssignmentEntitlement(resource, roleName, permissionOptions...))
 
-	return rv, "", nil, nil
-}
+		return rv, "", nil, nil
+	}
 
-// Grants always returns an empty slice for roles since they don't have any entitlements.
-func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
-	// parse the token
-	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: domainResourceType})
+	// account scoped roles are granted on specific account, create entitlement for each of them
+	accounts, err := r.listAccounts(ctx)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	switch bag.ResourceTypeID() {
-	case domainResourceType:
-		// list and paginate through all domains
-		domains, nextDomainsCursor, err := r.client.ListDomains(ctx, bag.PageToken())
-		if err != nil {
-			return nil, "", nil, err
-		}
-
-		// remove old cursors from bag
-		bag.Pop()
-
-		// push next cursor for paginating domains
-		if nextDomainsCursor != "" {
-			bag.Push(
-				pagination.PageState{
-					ResourceTypeID: domainResourceType,
-					Token:          nextDomainsCursor,
-				},
-			)
+	for _, account := range accounts {
+		permissionOptions := []ent.EntitlementOption{
+			ent.WithGrantableTo(groupResourceType),
+			ent.WithDisplayName(fmt.Sprintf("%s on account %d", resource.DisplayName, account.ID)),
+			ent.WithDescription(fmt.Sprintf("%s access to %s role on %s account in NewRelic", roleMembership, resource.DisplayName, account.Name)),
 		}
 
-		for _, d := range domains {
-			if d.Total == 0 {
-				continue
-			}
-
-			// push cursor for paginating groups under domain
-			bag.Push(
-				pagination.PageState{
-					ResourceTypeID: groupResourceType.Id,
-					Token:          fmt.Sprintf("%s:", d.ID),
-				},
-			)
-		}
-
-		next, err := bag.NextToken(bag.PageToken())
-		if err != nil {
-			return nil, "", nil, err
-		}
-
-		return nil, next, nil, nil
+		rv = append(rv, ent.NewAssignmentEntitlement(resource, accountEntitlementSlug(roleName, account.ID), permissionOptions...))
+	}
 
-	case groupResourceType.Id:
-		// list and paginate through groups under specific domain
-		parts := strings.Split(bag.PageToken(), ":")
-		if len(parts) != 2 {
-			return nil, "", nil, fmt.Errorf("invalid page token: %s (type: %s)", bag.PageToken(), bag.ResourceTypeID())
-		}
+	return rv, "", annotationsForRateLimit(r.client), nil
+}
 
-		domainId := parts[0]
-		cursor := parts[1]
+// Grants returns grants of the role to groups, account scoped grants are attached to entitlement of the account they apply to.
+// Grants are read from the index of role grants shared by all roles, see roleBuilder.groupRoleGrants.
+func (r *roleBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
 
-		// get role trait
-		rolesTrait, err := rs.GetRoleTrait(resource)
-		if err != nil {
-			return nil, "", nil, err
-		}
+	// get role trait
+	rolesTrait, err := rs.GetRoleTrait(resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
 
-		// get role name for entitlement id
-		roleName, ok := rs.GetProfileStringValue(rolesTrait.Profile, "role_name")
-		if !ok {
-			return nil, "", nil, fmt.Errorf("unable to get role name from role trait profile")
-		}
+	// get role name for entitlement id
+	roleName, ok := rs.GetProfileStringValue(rolesTrait.Profile, "role_name")
+	if !ok {
+		return nil, "", nil, fmt.Errorf("unable to get role name from role trait profile")
+	}
 
-		// list all groups within all domains with specific role
-		groups, nextGroupsCursor, err := r.client.ListGroupsWithRole(ctx, domainId, resource.Id.Resource, cursor)
-		if err != nil {
-			return nil, "", nil, err
-		}
+	roleScope, ok := rs.GetProfileStringValue(rolesTrait.Profile, "role_scope")
+	if !ok {
+		return nil, "", nil, fmt.Errorf("unable to get role scope from role trait profile")
+	}
 
-		c, err := composeCursor(domainId, nextGroupsCursor)
-		if err != nil {
-			return nil, "", nil, err
-		}
+	roleGrants, err := r.groupRoleGrants(ctx, resource.Id.Resource)
+	if err != nil {
+		return nil, "", nil, err
+	}
 
-		next, err := bag.NextToken(c)
-		if err != nil {
-			return nil, "", nil, err
-		}
+	var rv []*v2.Grant
+	granted := make(map[string]struct{})
+	for _, ag := range roleGrants {
+		// account scoped grants belong to entitlement of the account they apply to
+		entitlementSlug := roleName
+		if roleScope == accScope {
+			if ag.AccountID == 0 {
+				l.Warn(
+					"newrelic-connector: account scoped role grant without account",
+					zap.String("role_id", resource.Id.Resource),
+					zap.String("group_id", ag.groupId),
+				)
 
-		var rv []*v2.Grant
-		for _, g := range groups {
-			// skip groups without roles
-			if g.Roles.TotalCount == 0 {
 				continue
 			}
 
-			rv = append(rv, grant.NewGrant(
-				resource,
-				roleName,
-				&v2.ResourceId{
-					ResourceType: groupResourceType.Id,
-					Resource:     g.ID,
-				},
-				grant.WithAnnotation(
-					&v2.GrantExpandable{
-						EntitlementIds: []string{fmt.Sprintf("group:%s:%s", g.ID, groupMembership)},
-					},
-				),
-			))
+			entitlementSlug = accountEntitlementSlug(roleName, ag.AccountID)
 		}
 
-		return rv, next, nil, nil
+		key := fmt.Sprintf("%s:%s", ag.groupId, entitlementSlug)
+		if _, ok := granted[key]; ok {
+			continue
+		}
 
-	default:
-		return nil, "", nil, fmt.Errorf("invalid resource type: %s", bag.ResourceTypeID())
+		granted[key] = struct{}{}
+
+		rv = append(rv, grant.NewGrant(
+			resource,
+			entitlementSlug,
+			&v2.ResourceId{
+				ResourceType: groupResourceType.Id,
+				Resource:     ag.groupId,
+			},
+			grant.WithAnnotation(
+				&v2.GrantExpandable{
+					EntitlementIds: []string{fmt.Sprintf("group:%s:%s", ag.groupId, groupMembership)},
+				},
+			),
+		))
 	}
+
+	return rv, "", annotationsForRateLimit(r.client), nil
 }
 
 const (
@@ -245,6 +290,26 @@ const (
 	groupScope = "group"
 )
 
+// accountEntitlementSlug composes entitlement slug of account scoped role on specific account.
+func accountEntitlementSlug(roleName string, accountId int) string {
+	return fmt.Sprintf("%s:%d", roleName, accountId)
+}
+
+// parseAccountId extracts account id from entitlement id of account scoped role.
+func parseAccountId(entitlementId string) (int, error) {
+	i := strings.LastIndex(entitlementId, ":")
+	if i == -1 {
+		return 0, fmt.Errorf("newrelic-connector: invalid account role entitlement id: %s", entitlementId)
+	}
+
+	accountId, err := strconv.Atoi(entitlementId[i+1:])
+	if err != nil {
+		return 0, fmt.Errorf("newrelic-connector: invalid account role entitlement id: %s", entitlementId)
+	}
+
+	return accountId, nil
+}
+
 func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
 	l := ctxzap.Extract(ctx)
 
@@ -269,22 +334,49 @@ func (r *roleBuilder) Grant(ctx context.Context, principal *v2.Resource, entitle
 		return nil, fmt.Errorf("unable to get role scope from role trait profile")
 	}
 
+	var accountId int
+	switch roleScope {
+	case orgScope, groupScope:
+	case accScope:
+		accountId, err = parseAccountId(entitlement.Id)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("newrelic-connector: role scope %s is not supported", roleScope)
+	}
+
 	roleId, groupId := entitlement.Resource.Id.Resource, principal.Id.Resource
+	granted, known, err := r.hasAccess(ctx, principal, roleId, roleScope, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	if known && granted {
+		l.Debug(
+			"newrelic-connector: role is already granted to the group",
+			zap.String("role_id", roleId),
+			zap.String("group_id", groupId),
+		)
+
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
 	switch roleScope {
 	case orgScope:
 		err = r.client.AddOrgRole(ctx, roleId, groupId)
 	case accScope:
-		err = r.client.AddAccountRole(ctx, roleId, groupId, r.client.AccountId)
+		err = r.client.AddAccountRole(ctx, roleId, groupId, accountId)
 	case groupScope:
 		err = r.client.AddGroupRole(ctx, roleId, groupId)
-	default:
-		return nil, fmt.Errorf("newrelic-connector: role scope %s is not supported", roleScope)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("newrelic-connector: failed to add role to group: %w", err)
+		return nil, wrapError(err, "newrelic-connector: failed to add role to group")
 	}
 
+	r.invalidateIndex()
+
 	return nil, nil
 }
 
@@ -315,25 +407,197 @@ func (r *roleBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.
 		return nil, fmt.Errorf("unable to get role scope from role trait profile")
 	}
 
+	var accountId int
+	switch roleScope {
+	case orgScope, groupScope:
+	case accScope:
+		accountId, err = parseAccountId(entitlement.Id)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("newrelic-connector: role scope %s is not supported", roleScope)
+	}
+
 	roleId, groupId := entitlement.Resource.Id.Resource, principal.Id.Resource
+	granted, known, err := r.hasAccess(ctx, principal, roleId, roleScope, accountId)
+	if err != nil {
+		return nil, err
+	}
+
+	if known && !granted {
+		l.Debug(
+			"newrelic-connector: role is not granted to the group",
+			zap.String("role_id", roleId),
+			zap.String("group_id", groupId),
+		)
+
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
 	switch roleScope {
 	case orgScope:
 		err = r.client.RemoveOrgRole(ctx, roleId, groupId)
 	case accScope:
-		err = r.client.RemoveAccountRole(ctx, roleId, groupId, r.client.AccountId)
+		err = r.client.RemoveAccountRole(ctx, roleId, groupId, accountId)
 	case groupScope:
 		err = r.client.RemoveGroupRole(ctx, roleId, groupId)
-	default:
-		return nil, fmt.Errorf("newrelic-connector: role scope %s is not supported", roleScope)
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("newrelic-connector: failed to remove role from group: %w", err)
+		return nil, wrapError(err, "newrelic-connector: failed to remove role from group")
 	}
 
+	r.invalidateIndex()
+
 	return nil, nil
 }
 
+// hasAccess reports whether the role is granted to the group, on the account for account scoped roles.
+// Access is known only for groups with authentication domain in the parent or the profile.
+func (r *roleBuilder) hasAccess(ctx context.Context, group *v2.Resource, roleId, roleScope string, accountId int) (bool, bool, error) {
+	domainId, err := groupDomain(group)
+	if err != nil {
+		return false, false, nil
+	}
+
+	cursor := ""
+	for {
+		roleGrants, nextCursor, err := r.client.ListGroupRoleGrants(ctx, domainId, group.Id.Resource, roleId, cursor)
+		if err != nil {
+			return false, false, wrapError(err, "newrelic-connector: failed to list group role grants")
+		}
+
+		for _, rg := range roleGrants {
+			if strconv.Itoa(rg.RoleID) != roleId {
+				continue
+			}
+
+			if roleScope != accScope || rg.AccountID == accountId {
+				return true, true, nil
+			}
+		}
+
+		if nextCursor == "" {
+			return false, true, nil
+		}
+
+		cursor = nextCursor
+	}
+}
+
+// Create creates a custom role granting permissions from `permission_ids` field of the role profile.
+// Only new roles are created, resource with an id of existing role is rejected rather than replacing its permissions,
+// custom roles are not updated as there is no operation for updating resources.
+func (r *roleBuilder) Create(ctx context.Context, resource *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	if resource.Id != nil && resource.Id.Resource != "" {
+		return nil, nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: role %s already exists, only new custom roles can be created", resource.Id.Resource)
+	}
+
+	if resource.DisplayName == "" {
+		return nil, nil, status.Error(codes.InvalidArgument, "newrelic-connector: role name is required")
+	}
+
+	roleTrait, err := rs.GetRoleTrait(resource)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	roleScope, ok := rs.GetProfileStringValue(roleTrait.Profile, "role_scope")
+	if !ok || roleScope == "" {
+		roleScope = orgScope
+	}
+
+	permissionIds, err := parsePermissionIds(roleTrait.Profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	orgId, err := r.orgId(ctx, resource.ParentResourceId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role := &newrelic.Role{
+		DisplayName: resource.DisplayName,
+		Name:        resource.DisplayName,
+		Scope:       roleScope,
+		Type:        newrelic.RoleTypeCustom,
+	}
+
+	role.ID, err = r.client.CreateCustomRole(ctx, orgId, role.Name, roleScope, permissionIds)
+	if err != nil {
+		return nil, nil, wrapError(err, "newrelic-connector: failed to create custom role")
+	}
+
+	permissions, err := r.listRolesPermissions(ctx, []string{role.ID})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rr, err := roleResource(ctx, resource.ParentResourceId, role, permissions[role.ID])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rr, nil, nil
+}
+
+// Delete deletes the custom role, standard roles are rejected by NewRelic.
+func (r *roleBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	if resourceId.ResourceType != roleResourceType.Id {
+		return nil, fmt.Errorf("newrelic-connector: invalid resource type: %s", resourceId.ResourceType)
+	}
+
+	err := r.client.DeleteCustomRole(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to delete custom role")
+	}
+
+	return nil, nil
+}
+
+// orgId returns id of the organization from the parent resource, falls back to the organization of the API key.
+func (r *roleBuilder) orgId(ctx context.Context, pId *v2.ResourceId) (string, error) {
+	if pId != nil && pId.ResourceType == orgResourceType.Id && pId.Resource != "" {
+		return pId.Resource, nil
+	}
+
+	org, err := r.client.GetOrg(ctx)
+	if err != nil {
+		return "", wrapError(err, "newrelic-connector: failed to get organization")
+	}
+
+	return org.ID, nil
+}
+
+// parsePermissionIds reads ids of permissions from the role profile, ids can be either numbers or strings.
+func parsePermissionIds(profile *structpb.Struct) ([]int, error) {
+	value, ok := profile.GetFields()["permission_ids"]
+	if !ok || value.GetListValue() == nil {
+		return nil, status.Error(codes.InvalidArgument, "newrelic-connector: permission_ids are required in the role profile")
+	}
+
+	var rv []int
+	for _, v := range value.GetListValue().GetValues() {
+		switch kind := v.GetKind().(type) {
+		case *structpb.Value_NumberValue:
+			rv = append(rv, int(kind.NumberValue))
+		case *structpb.Value_StringValue:
+			id, err := strconv.Atoi(kind.StringValue)
+			if err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: invalid permission id: %s", kind.StringValue)
+			}
+
+			rv = append(rv, id)
+		default:
+			return nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: invalid permission id: %v", v)
+		}
+	}
+
+	return rv, nil
+}
+
 func newRoleBuilder(client *newrelic.Client) *roleBuilder {
 	return &roleBuilder{
 		resourceType: roleResourceType,
@@ -0,0 +1,602 @@
+package connector_test
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/connector"
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	"github.com/conductorone/baton-newrelic/pkg/newrelic/newrelictest"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
+	"github.com/conductorone/baton-sdk/pkg/dotc1z"
+	sdkSync "github.com/conductorone/baton-sdk/pkg/sync"
+	"github.com/conductorone/baton-sdk/pkg/types"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// connectorClient is the client side of the connector served over in-memory gRPC connection.
+type connectorClient struct {
+	v2.ResourceTypesServiceClient
+	v2.ResourcesServiceClient
+	v2.EntitlementsServiceClient
+	v2.GrantsServiceClient
+	v2.ConnectorServiceClient
+	v2.AssetServiceClient
+	v2.GrantManagerServiceClient
+	v2.ResourceManagerServiceClient
+	v2.AccountManagerServiceClient
+	v2.CredentialManagerServiceClient
+	v2.EventServiceClient
+	v2.TicketsServiceClient
+}
+
+// serveConnector runs the connector talking to the fake server and returns a client of it, the same way
+// the SDK runs connectors out of process.
+func serveConnector(t *testing.T, ctx context.Context, fake *newrelictest.Server) types.ConnectorClient {
+	t.Helper()
+
+	nr, err := connector.New(ctx, "test-api-key", newrelic.WithBaseURL(fake.URL), newrelic.WithMaxRetries(0))
+	if err != nil {
+		t.Fatalf("failed to create connector: %v", err)
+	}
+
+	srv, err := connectorbuilder.NewConnector(ctx, nr)
+	if err != nil {
+		t.Fatalf("failed to create connector server: %v", err)
+	}
+
+	listener := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	v2.RegisterResourceTypesServiceServer(s, srv)
+	v2.RegisterResourcesServiceServer(s, srv)
+	v2.RegisterEntitlementsServiceServer(s, srv)
+	v2.RegisterGrantsServiceServer(s, srv)
+	v2.RegisterConnectorServiceServer(s, srv)
+	v2.RegisterAssetServiceServer(s, srv)
+	v2.RegisterGrantManagerServiceServer(s, srv)
+	v2.RegisterResourceManagerServiceServer(s, srv)
+	v2.RegisterAccountManagerServiceServer(s, srv)
+	v2.RegisterCredentialManagerServiceServer(s, srv)
+	v2.RegisterEventServiceServer(s, srv)
+	v2.RegisterTicketsServiceServer(s, srv)
+
+	go func() {
+		_ = s.Serve(listener)
+	}()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to connect to connector: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &connectorClient{
+		ResourceTypesServiceClient:     v2.NewResourceTypesServiceClient(conn),
+		ResourcesServiceClient:         v2.NewResourcesServiceClient(conn),
+		EntitlementsServiceClient:      v2.NewEntitlementsServiceClient(conn),
+		GrantsServiceClient:            v2.NewGrantsServiceClient(conn),
+		ConnectorServiceClient:         v2.NewConnectorServiceClient(conn),
+		AssetServiceClient:             v2.NewAssetServiceClient(conn),
+		GrantManagerServiceClient:      v2.NewGrantManagerServiceClient(conn),
+		ResourceManagerServiceClient:   v2.NewResourceManagerServiceClient(conn),
+		AccountManagerServiceClient:    v2.NewAccountManagerServiceClient(conn),
+		CredentialManagerServiceClient: v2.NewCredentialManagerServiceClient(conn),
+		EventServiceClient:             v2.NewEventServiceClient(conn),
+		TicketsServiceClient:           v2.NewTicketsServiceClient(conn),
+	}
+}
+
+// seedOrg fills the fake server with an organization large enough to span several pages of every list.
+func seedOrg(s *newrelictest.Server) {
+	s.AddAccount(newrelictest.Account{ID: 100, Name: "Production"})
+	s.AddAccount(newrelictest.Account{ID: 200, Name: "Staging"})
+
+	s.AddDomain(newrelictest.Domain{ID: "d1", Name: "Default", ProvisioningType: "MANUAL", AuthenticationType: "PASSWORD"})
+	s.AddDomain(newrelictest.Domain{ID: "d2", Name: "Okta", ProvisioningType: "SCIM", AuthenticationType: "SAML_SSO"})
+	s.AddDomain(newrelictest.Domain{ID: "d3", Name: "Empty", ProvisioningType: "MANUAL", AuthenticationType: "PASSWORD"})
+
+	lastActive := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, u := range []newrelictest.User{
+		{ID: "1", Email: "alice@example.com", Name: "Alice", DomainID: "d1", Tier: newrelic.UserTierFull, LastActive: &lastActive},
+		{ID: "2", Email: "bob@example.com", Name: "Bob", DomainID: "d1", Tier: newrelic.UserTierCore},
+		{ID: "3", Email: "carol@example.com", Name: "Carol", DomainID: "d1"},
+		{ID: "4", Email: "dave@example.com", Name: "Dave", DomainID: "d2"},
+		{ID: "5", Email: "erin@example.com", Name: "Erin", DomainID: "d2", EmailVerificationState: newrelic.EmailVerificationPending},
+	} {
+		s.AddUser(u)
+	}
+
+	s.AddGroup(newrelictest.Group{ID: "g1", Name: "Admins", DomainID: "d1", Members: []string{"1", "2", "3"}})
+	s.AddGroup(newrelictest.Group{ID: "g2", Name: "Developers", DomainID: "d1", Members: []string{"2"}})
+	s.AddGroup(newrelictest.Group{ID: "g3", Name: "Support", DomainID: "d1"})
+	s.AddGroup(newrelictest.Group{ID: "g4", Name: "Okta Users", DomainID: "d2", Members: []string{"4", "5"}})
+
+	s.AddPermission(newrelictest.Permission{ID: 1, Name: "Read dashboards", Feature: "Dashboards", Category: "READ"})
+	s.AddPermission(newrelictest.Permission{ID: 2, Name: "Modify dashboards", Feature: "Dashboards", Category: "MODIFY"})
+	s.AddPermission(newrelictest.Permission{ID: 3, Name: "Delete dashboards", Feature: "Dashboards", Category: "DELETE"})
+
+	s.AddRole(newrelictest.Role{
+		ID: "10", Name: "all_product_admin", DisplayName: "All Product Admin", Scope: "account", Type: "standard",
+		PermissionIDs: []int{1, 2, 3},
+	})
+	s.AddRole(newrelictest.Role{ID: "11", Name: "read_only", DisplayName: "Read Only", Scope: "account", Type: "standard"})
+	s.AddRole(newrelictest.Role{
+		ID: "20", Name: "organization_manager", DisplayName: "Organization Manager", Scope: "organization", Type: "standard",
+		PermissionIDs: []int{2},
+	})
+	s.AddRole(newrelictest.Role{ID: "30", Name: "group_admin", DisplayName: "Group Admin", Scope: "group", Type: "standard"})
+
+	s.AddRoleGrant(newrelictest.RoleGrant{RoleID: "10", GroupID: "g1", AccountID: 100})
+	s.AddRoleGrant(newrelictest.RoleGrant{RoleID: "10", GroupID: "g1", AccountID: 200})
+	s.AddRoleGrant(newrelictest.RoleGrant{RoleID: "20", GroupID: "g1"})
+	s.AddRoleGrant(newrelictest.RoleGrant{RoleID: "10", GroupID: "g2", AccountID: 200})
+	s.AddRoleGrant(newrelictest.RoleGrant{RoleID: "30", GroupID: "g4"})
+
+	s.AddAPIKey(newrelictest.APIKey{ID: "k1", Name: "Alice key", Type: newrelic.APIKeyTypeUser, AccountID: 100, UserID: "1", CreatedAt: lastActive})
+	s.AddAPIKey(newrelictest.APIKey{ID: "k2", Name: "License", Type: newrelic.APIKeyTypeIngest, AccountID: 100, IngestType: "LICENSE", CreatedAt: lastActive})
+}
+
+// syncedData holds the contents of the c1z written by the sync.
+type syncedData struct {
+	resources    map[string][]string
+	byId         map[string]*v2.Resource
+	entitlements map[string]bool
+	grants       map[string][]string
+}
+
+// resource returns the synced resource of the type with the id.
+func (d *syncedData) resource(t *testing.T, resourceType, id string) *v2.Resource {
+	t.Helper()
+
+	r, ok := d.byId[resourceType+":"+id]
+	if !ok {
+		t.Fatalf("expected %s %s to be synced", resourceType, id)
+	}
+
+	return r
+}
+
+func readC1Z(t *testing.T, ctx context.Context, path string) *syncedData {
+	t.Helper()
+
+	f, err := dotc1z.NewC1ZFile(ctx, path, dotc1z.WithTmpDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to open c1z: %v", err)
+	}
+	defer f.Close()
+
+	data := &syncedData{
+		resources:    make(map[string][]string),
+		byId:         make(map[string]*v2.Resource),
+		entitlements: make(map[string]bool),
+		grants:       make(map[string][]string),
+	}
+
+	pageToken := ""
+	for {
+		resp, err := f.ListResources(ctx, &v2.ResourcesServiceListResourcesRequest{PageToken: pageToken})
+		if err != nil {
+			t.Fatalf("failed to list resources: %v", err)
+		}
+
+		for _, r := range resp.List {
+			data.resources[r.Id.ResourceType] = append(data.resources[r.Id.ResourceType], r.Id.Resource)
+			data.byId[r.Id.ResourceType+":"+r.Id.Resource] = r
+		}
+
+		if pageToken = resp.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	for {
+		resp, err := f.ListEntitlements(ctx, &v2.EntitlementsServiceListEntitlementsRequest{PageToken: pageToken})
+		if err != nil {
+			t.Fatalf("failed to list entitlements: %v", err)
+		}
+
+		for _, e := range resp.List {
+			data.entitlements[e.Id] = true
+		}
+
+		if pageToken = resp.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	for {
+		resp, err := f.ListGrants(ctx, &v2.GrantsServiceListGrantsRequest{PageToken: pageToken})
+		if err != nil {
+			t.Fatalf("failed to list grants: %v", err)
+		}
+
+		for _, g := range resp.List {
+			principal := g.Principal.Id.ResourceType + ":" + g.Principal.Id.Resource
+			data.grants[g.Entitlement.Id] = append(data.grants[g.Entitlement.Id], principal)
+		}
+
+		if pageToken = resp.NextPageToken; pageToken == "" {
+			break
+		}
+	}
+
+	for _, ids := range data.resources {
+		sort.Strings(ids)
+	}
+
+	for _, principals := range data.grants {
+		sort.Strings(principals)
+	}
+
+	return data
+}
+
+func assertIDs(t *testing.T, what string, got, want []string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Errorf("%s: expected %v, got %v", what, want, got)
+		return
+	}
+
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: expected %v, got %v", what, want, got)
+			return
+		}
+	}
+}
+
+// assertProfile checks values of the profile fields, nil values expect the field to be missing. Fields not listed are not checked.
+func assertProfile(t *testing.T, what string, profile *structpb.Struct, want map[string]interface{}) {
+	t.Helper()
+
+	got := profile.AsMap()
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s: expected %s to be %v, got %v", what, k, v, got[k])
+		}
+	}
+}
+
+// syncOrg runs full sync of the organization of the fake server and returns the synced data.
+func syncOrg(t *testing.T, ctx context.Context, fake *newrelictest.Server) *syncedData {
+	t.Helper()
+
+	client := serveConnector(t, ctx, fake)
+
+	c1zPath := filepath.Join(t.TempDir(), "sync.c1z")
+	syncer, err := sdkSync.NewSyncer(ctx, client, sdkSync.WithC1ZPath(c1zPath), sdkSync.WithTmpDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("failed to create syncer: %v", err)
+	}
+
+	if err := syncer.Sync(ctx); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+
+	if err := syncer.Close(ctx); err != nil {
+		t.Fatalf("failed to close syncer: %v", err)
+	}
+
+	return readC1Z(t, ctx, c1zPath)
+}
+
+// assertUser checks status and profile fields of the synced user, fields set to nil must be missing.
+func assertUser(t *testing.T, data *syncedData, id string, status v2.UserTrait_Status_Status, profile map[string]interface{}) {
+	t.Helper()
+
+	userTrait, err := rs.GetUserTrait(data.resource(t, "user", id))
+	if err != nil {
+		t.Fatalf("expected user %s to have user trait: %v", id, err)
+	}
+
+	if got := userTrait.GetStatus().GetStatus(); got != status {
+		t.Errorf("user %s: expected status %s, got %s", id, status, got)
+	}
+
+	assertProfile(t, "user "+id, userTrait.Profile, profile)
+}
+
+func TestSyncEndToEnd(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	data := syncOrg(t, ctx, fake)
+
+	assertIDs(t, "accounts", data.resources["account"], []string{"100", "200"})
+	assertIDs(t, "domains", data.resources["domain"], []string{"d1", "d2", "d3"})
+	assertIDs(t, "users", data.resources["user"], []string{"1", "2", "3", "4", "5"})
+	assertIDs(t, "groups", data.resources["group"], []string{"g1", "g2", "g3", "g4"})
+	assertIDs(t, "roles", data.resources["role"], []string{"10", "11", "20", "30"})
+	assertIDs(t, "api keys", data.resources["api_key"], []string{"k1", "k2"})
+
+	for _, id := range []string{
+		"group:g1:member",
+		"group:g3:member",
+		"role:10:all_product_admin:100",
+		"role:10:all_product_admin:200",
+		"role:11:read_only:100",
+		"role:11:read_only:200",
+		"role:20:organization_manager",
+		"role:30:group_admin",
+		"api_key:k1:owner",
+	} {
+		if !data.entitlements[id] {
+			t.Errorf("expected entitlement %s to be synced", id)
+		}
+	}
+
+	// group members are listed across several pages and batches
+	assertIDs(t, "members of g1", data.grants["group:g1:member"], []string{"user:1", "user:2", "user:3"})
+	assertIDs(t, "members of g4", data.grants["group:g4:member"], []string{"user:4", "user:5"})
+	assertIDs(t, "members of g3", data.grants["group:g3:member"], nil)
+
+	// roles granted to groups are expanded to members of the groups
+	assertIDs(t, "all product admins on 100", data.grants["role:10:all_product_admin:100"],
+		[]string{"group:g1", "user:1", "user:2", "user:3"})
+	assertIDs(t, "all product admins on 200", data.grants["role:10:all_product_admin:200"],
+		[]string{"group:g1", "group:g2", "user:1", "user:2", "user:3"})
+	assertIDs(t, "organization managers", data.grants["role:20:organization_manager"],
+		[]string{"group:g1", "user:1", "user:2", "user:3"})
+	assertIDs(t, "group admins", data.grants["role:30:group_admin"],
+		[]string{"group:g4", "user:4", "user:5"})
+
+	assertIDs(t, "owner of k1", data.grants["api_key:k1:owner"], []string{"user:1"})
+
+	// every user has grant of its tier
+	assertIDs(t, "full platform users", data.grants["user_tier:user_tier:full_platform"], []string{"user:1"})
+	assertIDs(t, "core users", data.grants["user_tier:user_tier:core"], []string{"user:2"})
+	assertIDs(t, "basic users", data.grants["user_tier:user_tier:basic"], []string{"user:3", "user:4", "user:5"})
+
+	// users pending email verification are not enabled yet, activity of users is synced into the profile
+	lastActive := map[string]interface{}{"last_active": "2024-01-02T03:04:05Z", "never_active": false}
+	neverActive := map[string]interface{}{"last_active": nil, "never_active": true}
+	assertUser(t, data, "1", v2.UserTrait_Status_STATUS_ENABLED, lastActive)
+	for _, id := range []string{"2", "3", "4"} {
+		assertUser(t, data, id, v2.UserTrait_Status_STATUS_ENABLED, neverActive)
+	}
+	assertUser(t, data, "5", v2.UserTrait_Status_STATUS_DISABLED, neverActive)
+
+	// type, account and creation time of keys are synced into the profile
+	for _, tc := range []struct {
+		id      string
+		profile map[string]interface{}
+	}{
+		{id: "k1", profile: map[string]interface{}{
+			"key_type":   newrelic.APIKeyTypeUser,
+			"account_id": float64(100),
+			"created_at": "2024-01-02T03:04:05Z",
+			"owner_id":   "1",
+		}},
+		{id: "k2", profile: map[string]interface{}{
+			"key_type":    newrelic.APIKeyTypeIngest,
+			"account_id":  float64(100),
+			"ingest_type": "LICENSE",
+			"created_at":  "2024-01-02T03:04:05Z",
+		}},
+	} {
+		appTrait, err := rs.GetAppTrait(data.resource(t, "api_key", tc.id))
+		if err != nil {
+			t.Fatalf("expected api key %s to have app trait: %v", tc.id, err)
+		}
+
+		assertProfile(t, "api key "+tc.id, appTrait.Profile, tc.profile)
+	}
+
+	// permissions of roles are kept in the profile, across several pages of permissions of the role
+	for _, tc := range []struct {
+		id          string
+		permissions []string
+	}{
+		{id: "10", permissions: []string{"1", "2", "3"}},
+		{id: "11"},
+		{id: "20", permissions: []string{"2"}},
+	} {
+		roleTrait, err := rs.GetRoleTrait(data.resource(t, "role", tc.id))
+		if err != nil {
+			t.Fatalf("expected role %s to have role trait: %v", tc.id, err)
+		}
+
+		var got []string
+		for _, id := range roleTrait.Profile.AsMap()["permission_ids"].([]interface{}) {
+			got = append(got, id.(string))
+		}
+
+		if strings.Join(got, ",") != strings.Join(tc.permissions, ",") {
+			t.Errorf("expected role %s to grant permissions %v, got %v", tc.id, tc.permissions, got)
+		}
+	}
+
+	// permissions are fetched for all roles of a page together, not once per role
+	if n := fake.Requests("ListRolesPermissions"); n != 3 {
+		t.Errorf("expected permissions of roles to be listed in 3 requests, got %d", n)
+	}
+
+	// grants of all roles are indexed once per sync, not once per role
+	if n := fake.Requests("ListGroupsWithRole"); n != 3 {
+		t.Errorf("expected groups with roles to be listed in 3 pages, got %d requests", n)
+	}
+
+	// users are listed once, user tier grants come from the same walk
+	if n := fake.Requests("ListUsers"); n != 3 {
+		t.Errorf("expected users to be listed in 3 pages, got %d requests", n)
+	}
+
+	// accounts are listed by the account builder and once for entitlements of all account scoped roles
+	if n := fake.Requests("ListAccounts"); n != 2 {
+		t.Errorf("expected accounts to be listed twice, got %d requests", n)
+	}
+}
+
+func TestSyncLegacyUsers(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	// organization without authentication domains, users come from legacy user search
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	fake.AddAccount(newrelictest.Account{ID: 100, Name: "Production"})
+	fake.AddUser(newrelictest.User{ID: "1", Email: "alice@example.com", Name: "Alice"})
+	fake.AddUser(newrelictest.User{ID: "2", Email: "bob@example.com", Name: "Bob"})
+
+	data := syncOrg(t, ctx, fake)
+
+	assertIDs(t, "users", data.resources["user"], []string{"1", "2"})
+
+	// status, type and activity are not known for legacy users
+	unknownActivity := map[string]interface{}{"last_active": nil, "never_active": nil}
+	for _, id := range []string{"1", "2"} {
+		assertUser(t, data, id, v2.UserTrait_Status_STATUS_UNSPECIFIED, unknownActivity)
+	}
+
+	for _, slug := range []string{"basic", "core", "full_platform"} {
+		assertIDs(t, slug+" users", data.grants["user_tier:user_tier:"+slug], nil)
+	}
+}
+
+// listGroups lists groups of all domains through every page, keyed by group id.
+func listGroups(t *testing.T, ctx context.Context, client types.ConnectorClient) map[string]*v2.Resource {
+	t.Helper()
+
+	rv := make(map[string]*v2.Resource)
+	token := ""
+	for {
+		resp, err := client.ListResources(ctx, &v2.ResourcesServiceListResourcesRequest{
+			ResourceTypeId:   "group",
+			ParentResourceId: &v2.ResourceId{ResourceType: "org", Resource: "org1"},
+			PageToken:        token,
+		})
+		if err != nil {
+			t.Fatalf("failed to list groups: %v", err)
+		}
+
+		for _, r := range resp.List {
+			rv[r.Id.Resource] = r
+		}
+
+		if resp.NextPageToken == "" {
+			return rv
+		}
+
+		token = resp.NextPageToken
+	}
+}
+
+// listMembers lists members of the group through every page.
+func listMembers(ctx context.Context, client types.ConnectorClient, group *v2.Resource) ([]string, error) {
+	var rv []string
+	token := ""
+	for {
+		resp, err := client.ListGrants(ctx, &v2.GrantsServiceListGrantsRequest{Resource: group, PageToken: token})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, g := range resp.List {
+			rv = append(rv, g.Principal.Id.Resource)
+		}
+
+		if resp.NextPageToken == "" {
+			return rv, nil
+		}
+
+		token = resp.NextPageToken
+	}
+}
+
+func TestGroupMembersBatches(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+	fake.AddGroup(newrelictest.Group{ID: "g5", Name: "Operators", DomainID: "d1", Members: []string{"1", "2", "3"}})
+
+	client := serveConnector(t, ctx, fake)
+	groups := listGroups(t, ctx, client)
+
+	// following pages of g1 and g5 are fetched in the same request
+	for _, tc := range []struct {
+		groupId string
+		members []string
+	}{
+		{groupId: "g1", members: []string{"1", "2", "3"}},
+		{groupId: "g5", members: []string{"1", "2", "3"}},
+		{groupId: "g2", members: []string{"2"}},
+		{groupId: "g3"},
+	} {
+		members, err := listMembers(ctx, client, groups[tc.groupId])
+		if err != nil {
+			t.Fatalf("failed to list members of %s: %v", tc.groupId, err)
+		}
+
+		assertIDs(t, "members of "+tc.groupId, members, tc.members)
+	}
+
+	if n := fake.Requests("ListGroupsMembers"); n != 2 {
+		t.Errorf("expected members of d1 groups to be fetched in 2 requests, got %d", n)
+	}
+}
+
+func TestGroupMembersOfDeletedGroup(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	fake := newrelictest.NewServer()
+	defer fake.Close()
+	seedOrg(fake)
+
+	client := serveConnector(t, ctx, fake)
+	groups := listGroups(t, ctx, client)
+
+	// the group is deleted after listing, while its members are fetched in a batch with other groups
+	nr, err := fake.Client(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nr.DeleteGroup(ctx, "g2"); err != nil {
+		t.Fatalf("failed to delete group: %v", err)
+	}
+
+	members, err := listMembers(ctx, client, groups["g1"])
+	if err != nil {
+		t.Fatalf("failed to list members of g1: %v", err)
+	}
+	assertIDs(t, "members of g1", members, []string{"1", "2", "3"})
+
+	if _, err := listMembers(ctx, client, groups["g2"]); status.Code(err) != codes.NotFound {
+		t.Errorf("expected members of deleted group to be not found, got %v", err)
+	}
+
+	members, err = listMembers(ctx, client, groups["g3"])
+	if err != nil {
+		t.Fatalf("failed to list members of g3: %v", err)
+	}
+	assertIDs(t, "members of g3", members, nil)
+}
@@ -0,0 +1,87 @@
+package connector
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+)
+
+// userTierIndex keeps user types of users listed by the user builder, so grants of user tiers
+// are emitted from the same walk through users instead of listing all users again.
+type userTierIndex struct {
+	mu sync.Mutex
+	// tiers collected during the current pagination of users, keyed by user id
+	listing map[string]string
+	// tiers of the last complete pagination of users, nil until users were listed to the end
+	tiers map[string]string
+}
+
+func newUserTierIndex() *userTierIndex {
+	return &userTierIndex{}
+}
+
+// reset starts collecting tiers of a new pagination of users, tiers of previous syncs are dropped.
+func (x *userTierIndex) reset() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.listing = make(map[string]string)
+	x.tiers = nil
+}
+
+// add records tiers of the listed users, users from legacy user search have no type and are skipped.
+func (x *userTierIndex) add(users []newrelic.User) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.listing == nil {
+		return
+	}
+
+	for _, user := range users {
+		if tier := user.Type.Tier(); tier != "" {
+			x.listing[user.ID] = tier
+		}
+	}
+}
+
+// complete marks the pagination of users as finished, the collected tiers become available.
+func (x *userTierIndex) complete() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.listing == nil {
+		return
+	}
+
+	x.tiers = x.listing
+	x.listing = nil
+}
+
+// userTier is the user type of the user.
+type userTier struct {
+	userId string
+	tier   string
+}
+
+// users returns tiers of all users ordered by user id, false if users were not listed to the end yet.
+func (x *userTierIndex) users() ([]userTier, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	if x.tiers == nil {
+		return nil, false
+	}
+
+	rv := make([]userTier, 0, len(x.tiers))
+	for userId, tier := range x.tiers {
+		rv = append(rv, userTier{userId: userId, tier: tier})
+	}
+
+	sort.Slice(rv, func(i, j int) bool {
+		return rv[i].userId < rv[j].userId
+	})
+
+	return rv, true
+}
@@ -0,0 +1,279 @@
+package connector
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
+	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/pagination"
+	ent "github.com/conductorone/baton-sdk/pkg/types/entitlement"
+	"github.com/conductorone/baton-sdk/pkg/types/grant"
+	rs "github.com/conductorone/baton-sdk/pkg/types/resource"
+	"github.com/grpc-ecosystem/go-grpc-middleware/logging/zap/ctxzap"
+	"go.uber.org/zap"
+)
+
+const userTierId = "user_tier"
+
+// userTiers maps entitlement slugs to the user types users can be assigned.
+var userTiers = []struct {
+	slug        string
+	displayName string
+	tier        string
+}{
+	{slug: "basic", displayName: "Basic", tier: newrelic.UserTierBasic},
+	{slug: "core", displayName: "Core", tier: newrelic.UserTierCore},
+	{slug: "full_platform", displayName: "Full Platform", tier: newrelic.UserTierFull},
+}
+
+type userTierBuilder struct {
+	resourceType *v2.ResourceType
+	client       *newrelic.Client
+
+	// user types of users listed by the user builder during the sync
+	tiers *userTierIndex
+}
+
+func (u *userTierBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
+	return userTierResourceType
+}
+
+func userTierResource(ctx context.Context, pId *v2.ResourceId) (*v2.Resource, error) {
+	resource, err := rs.NewResource(
+		"User Tier",
+		userTierResourceType,
+		userTierId,
+		rs.WithParentResourceID(pId),
+		rs.WithDescription("User type (Basic, Core or Full Platform) of NewRelic users"),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return resource, nil
+}
+
+// List returns single user tier resource, user types are represented by its entitlements.
+func (u *userTierBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
+	if parentResourceID == nil {
+		return nil, "", nil, nil
+	}
+
+	ur, err := userTierResource(ctx, parentResourceID)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	return []*v2.Resource{ur}, "", nil, nil
+}
+
+// Entitlements returns entitlement for each user type.
+func (u *userTierBuilder) Entitlements(_ context.Context, resource *v2.Resource, _ *pagination.Token) ([]*v2.Entitlement, string, annotations.Annotations, error) {
+	var rv []*v2.Entitlement
+
+	for _, t := range userTiers {
+		permissionOptions := []ent.EntitlementOption{
+			ent.WithGrantableTo(userResourceType),
+			ent.WithDisplayName(fmt.Sprintf("%s User", t.displayName)),
+			ent.WithDescription(fmt.Sprintf("%s user type in NewRelic", t.displayName)),
+		}
+
+		rv = append(rv, ent.NewAssignmentEntitlement(resource, t.slug, permissionOptions...))
+	}
+
+	return rv, "", nil, nil
+}
+
+// Grants returns user type of every user, users from legacy user search have no type.
+// Types are taken from the users listed during the sync, users are listed again only if they were not.
+func (u *userTierBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken *pagination.Token) ([]*v2.Grant, string, annotations.Annotations, error) {
+	if pToken.Token == "" {
+		if users, ok := u.tiers.users(); ok {
+			var rv []*v2.Grant
+			for _, user := range users {
+				rv = append(rv, userTierGrant(resource, user.userId, user.tier))
+			}
+
+			return rv, "", nil, nil
+		}
+	}
+
+	users, next, err := listUsersPage(ctx, u.client, pToken)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	var rv []*v2.Grant
+	for _, user := range users {
+		if user.Type.Tier() == "" {
+			continue
+		}
+
+		rv = append(rv, userTierGrant(resource, user.ID, user.Type.Tier()))
+	}
+
+	return rv, next, annotationsForRateLimit(u.client), nil
+}
+
+// userTierGrant returns grant of the user tier to the user.
+func userTierGrant(resource *v2.Resource, userId, tier string) *v2.Grant {
+	return grant.NewGrant(
+		resource,
+		userTierSlug(tier),
+		&v2.ResourceId{
+			ResourceType: userResourceType.Id,
+			Resource:     userId,
+		},
+	)
+}
+
+// Grant changes user type of the user to the type of entitlement, users who already have the type are left as they are.
+func (u *userTierBuilder) Grant(ctx context.Context, principal *v2.Resource, entitlement *v2.Entitlement) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	if principal.Id.ResourceType != userResourceType.Id {
+		l.Warn(
+			"newrelic-connector: only users can be granted user tier",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("newrelic-connector: only users can be granted user tier")
+	}
+
+	tier, err := parseUserTier(entitlement.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	domainId, err := userDomain(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.client.GetUser(ctx, domainId, principal.Id.Resource)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to get user")
+	}
+
+	if user.Type.Tier() == tier {
+		l.Debug(
+			"newrelic-connector: user already has the user tier",
+			zap.String("user_id", principal.Id.Resource),
+			zap.String("user_tier", tier),
+		)
+
+		return annotations.New(&v2.GrantAlreadyExists{}), nil
+	}
+
+	err = u.client.UpdateUserType(ctx, principal.Id.Resource, tier)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to update user type")
+	}
+
+	return nil, nil
+}
+
+// Revoke downgrades the user to basic user type, since every user has to have a type.
+// Users of another user type than the revoked one are left as they are.
+func (u *userTierBuilder) Revoke(ctx context.Context, grant *v2.Grant) (annotations.Annotations, error) {
+	l := ctxzap.Extract(ctx)
+
+	principal := grant.Principal
+	entitlement := grant.Entitlement
+
+	if principal.Id.ResourceType != userResourceType.Id {
+		l.Warn(
+			"newrelic-connector: only users can have user tier revoked",
+			zap.String("principal_id", principal.Id.String()),
+			zap.String("principal_type", principal.Id.ResourceType),
+		)
+
+		return nil, fmt.Errorf("newrelic-connector: only users can have user tier revoked")
+	}
+
+	tier, err := parseUserTier(entitlement.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	if tier == newrelic.UserTierBasic {
+		return nil, fmt.Errorf("newrelic-connector: basic user tier cannot be revoked, grant another user tier instead")
+	}
+
+	domainId, err := userDomain(principal)
+	if err != nil {
+		return nil, err
+	}
+
+	// the revoke could be stale or retried, don't downgrade users who have another type by now
+	user, err := u.client.GetUser(ctx, domainId, principal.Id.Resource)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to get user")
+	}
+
+	if user.Type.Tier() != tier {
+		l.Debug(
+			"newrelic-connector: user does not have the user tier",
+			zap.String("user_id", principal.Id.Resource),
+			zap.String("user_tier", tier),
+			zap.String("current_user_tier", user.Type.Tier()),
+		)
+
+		return annotations.New(&v2.GrantAlreadyRevoked{}), nil
+	}
+
+	err = u.client.UpdateUserType(ctx, principal.Id.Resource, newrelic.UserTierBasic)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to update user type")
+	}
+
+	return nil, nil
+}
+
+// userTierSlug returns entitlement slug of the user tier.
+func userTierSlug(tier string) string {
+	for _, t := range userTiers {
+		if t.tier == tier {
+			return t.slug
+		}
+	}
+
+	return ""
+}
+
+// lookupUserTier returns the user tier by its entitlement slug, tier name or display name,
+// as kept in `user_type` profile field of synced users.
+func lookupUserTier(value string) (string, bool) {
+	for _, t := range userTiers {
+		if strings.EqualFold(t.slug, value) || strings.EqualFold(t.tier, value) || strings.EqualFold(t.displayName, value) {
+			return t.tier, true
+		}
+	}
+
+	return "", false
+}
+
+// parseUserTier returns the user tier from entitlement id of user tier.
+func parseUserTier(entitlementId string) (string, error) {
+	slug := entitlementId[strings.LastIndex(entitlementId, ":")+1:]
+	for _, t := range userTiers {
+		if t.slug == slug {
+			return t.tier, nil
+		}
+	}
+
+	return "", fmt.Errorf("newrelic-connector: invalid user tier entitlement id: %s", entitlementId)
+}
+
+func newUserTierBuilder(client *newrelic.Client, tiers *userTierIndex) *userTierBuilder {
+	return &userTierBuilder{
+		resourceType: userTierResourceType,
+		client:       client,
+		tiers:        tiers,
+	}
+}
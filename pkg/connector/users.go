@@ -2,24 +2,42 @@ package connector
 
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/conductorone/baton-newrelic/pkg/newrelic"
 	v2 "github.com/conductorone/baton-sdk/pb/c1/connector/v2"
 	"github.com/conductorone/baton-sdk/pkg/annotations"
+	"github.com/conductorone/baton-sdk/pkg/connectorbuilder"
 	"github.com/conductorone/baton-sdk/pkg/helpers"
 	"github.com/conductorone/baton-sdk/pkg/pagination"
 	"github.com/conductorone/baton-sdk/pkg/types/resource"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type userBuilder struct {
 	resourceType *v2.ResourceType
 	client       *newrelic.Client
+
+	// users already listed during the current pagination, used to deduplicate users across domains
+	mu   sync.Mutex
+	seen map[string]struct{}
+
+	// user types of listed users, shared with the user tier builder
+	tiers *userTierIndex
 }
 
 func (u *userBuilder) ResourceType(ctx context.Context) *v2.ResourceType {
 	return userResourceType
 }
 
+// userResource creates user resource, parent of the user is the authentication domain it belongs to
+// or the organization for users without domain.
 func userResource(ctx context.Context, pId *v2.ResourceId, user *newrelic.User) (*v2.Resource, error) {
 	firstName, lastName := helpers.SplitFullName(user.Name)
 	profile := map[string]interface{}{
@@ -29,16 +47,38 @@ func userResource(ctx context.Context, pId *v2.ResourceId, user *newrelic.User)
 		"last_name":  lastName,
 	}
 
+	if user.DomainID != "" {
+		profile["user_domain"] = user.DomainID
+		pId = domainResourceId(user.DomainID)
+	}
+
+	if user.Type.DisplayName != "" {
+		profile["user_type"] = user.Type.DisplayName
+	}
+
+	userTraitOptions := []resource.UserTraitOption{
+		resource.WithEmail(user.Email, true),
+		resource.WithUserLogin(user.Email),
+		resource.WithStatus(userStatus(user)),
+	}
+
+	// activity is not available for users from legacy user search, keep it missing rather than never active
+	if !user.IsLegacy() {
+		profile["email_verification_state"] = user.EmailVerificationState
+		profile["time_zone"] = user.TimeZone
+		profile["never_active"] = user.LastActive == nil
+
+		if user.LastActive != nil {
+			profile["last_active"] = user.LastActive.Format(time.RFC3339)
+			userTraitOptions = append(userTraitOptions, resource.WithLastLogin(*user.LastActive))
+		}
+	}
+
 	resource, err := resource.NewUserResource(
 		user.Name,
 		userResourceType,
 		user.ID,
-		[]resource.UserTraitOption{
-			resource.WithUserProfile(profile),
-			resource.WithEmail(user.Email, true),
-			resource.WithUserLogin(user.Email),
-			resource.WithStatus(v2.UserTrait_Status_STATUS_ENABLED),
-		},
+		append(userTraitOptions, resource.WithUserProfile(profile)),
 		resource.WithParentResourceID(pId),
 	)
 
@@ -49,61 +89,221 @@ func userResource(ctx context.Context, pId *v2.ResourceId, user *newrelic.User)
 	return resource, nil
 }
 
+// userDomain returns id of authentication domain of the user, taken from the parent or the profile of the user.
+func userDomain(user *v2.Resource) (string, error) {
+	if pId := user.ParentResourceId; pId != nil && pId.ResourceType == domainResourceType.Id && pId.Resource != "" {
+		return pId.Resource, nil
+	}
+
+	if userTrait, err := resource.GetUserTrait(user); err == nil {
+		if domainId, ok := resource.GetProfileStringValue(userTrait.Profile, "user_domain"); ok && domainId != "" {
+			return domainId, nil
+		}
+	}
+
+	return "", status.Error(codes.InvalidArgument, "newrelic-connector: authentication domain of the user is required")
+}
+
+// userStatus returns status of the user, users pending email verification are not enabled yet.
+// Status of users from legacy user search is unknown.
+func userStatus(user *newrelic.User) v2.UserTrait_Status_Status {
+	switch {
+	case user.IsLegacy():
+		return v2.UserTrait_Status_STATUS_UNSPECIFIED
+	case user.IsPendingVerification():
+		return v2.UserTrait_Status_STATUS_DISABLED
+	default:
+		return v2.UserTrait_Status_STATUS_ENABLED
+	}
+}
+
+// legacyUsersState is the pagination state for users of organizations without authentication domains.
+const legacyUsersState = "user_search"
+
 // List returns all the users from the database as resource objects.
 // Users include a UserTrait because they are the 'shape' of a standard user.
+// Users are listed per authentication domain, each domain paginated with its own cursor.
 func (u *userBuilder) List(ctx context.Context, parentResourceID *v2.ResourceId, pToken *pagination.Token) ([]*v2.Resource, string, annotations.Annotations, error) {
-	var (
-		nextCursor, domainID string
-		users                []newrelic.User
-	)
 	if parentResourceID == nil {
 		return nil, "", nil, nil
 	}
 
-	// parse the token
-	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: userResourceType.Id})
-	if err != nil {
-		return nil, "", nil, err
+	// start of the pagination, forget users seen in previous syncs
+	if pToken.Token == "" {
+		u.resetSeen()
+		u.tiers.reset()
 	}
 
-	domains, _, err := u.client.ListDomains(ctx, bag.PageToken())
+	users, next, err := listUsersPage(ctx, u.client, pToken)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	if len(domains) == 1 {
-		for _, domain := range domains {
-			domainID = domain.ID
-		}
-	}
-
-	if len(domains) == 0 || len(domains) > 1 { // no domains or multiple domains
-		domainID = ""
+	u.tiers.add(users)
+	if next == "" {
+		u.tiers.complete()
 	}
 
-	users, nextCursor, err = u.client.ListUsers(ctx, domainID, bag.PageToken())
+	rv, err := u.userResources(ctx, parentResourceID, users)
 	if err != nil {
 		return nil, "", nil, err
 	}
 
-	// add next cursor to bag
-	next, err := bag.NextToken(nextCursor)
+	return rv, next, annotationsForRateLimit(u.client), nil
+}
+
+// listUsersPage returns next page of users, walking through all authentication domains of the organization.
+// Pages which only advance through domains return no users.
+func listUsersPage(ctx context.Context, client *newrelic.Client, pToken *pagination.Token) ([]newrelic.User, string, error) {
+	// parse the token
+	bag, err := parsePageToken(pToken.Token, &v2.ResourceId{ResourceType: domainResourceType.Id})
 	if err != nil {
-		return nil, "", nil, err
+		return nil, "", err
 	}
 
+	switch bag.ResourceTypeID() {
+	case domainResourceType.Id:
+		// list and paginate through domains
+		firstPage := bag.PageToken() == ""
+		domains, nextDomainsCursor, err := client.ListDomains(ctx, bag.PageToken())
+		if err != nil {
+			return nil, "", wrapError(err, "newrelic-connector: failed to list domains")
+		}
+
+		// remove old cursors from bag
+		bag.Pop()
+
+		// add cursor for paginating next domains to bag
+		if nextDomainsCursor != "" {
+			bag.Push(
+				pagination.PageState{
+					ResourceTypeID: domainResourceType.Id,
+					Token:          nextDomainsCursor,
+				},
+			)
+		}
+
+		// organization without domains, fall back to legacy user search
+		if firstPage && len(domains) == 0 {
+			bag.Push(
+				pagination.PageState{
+					ResourceTypeID: legacyUsersState,
+				},
+			)
+		}
+
+		for _, d := range domains {
+			if d.UsersTotal == 0 {
+				continue
+			}
+
+			token, err := pageState{DomainID: d.ID}.encode()
+			if err != nil {
+				return nil, "", err
+			}
+
+			// add cursors for paginating users under this domain
+			bag.Push(
+				pagination.PageState{
+					ResourceTypeID: userResourceType.Id,
+					Token:          token,
+				},
+			)
+		}
+
+		// handle next iteration, empty token if there are no more cursors
+		next, err := bag.Marshal()
+		if err != nil {
+			return nil, "", err
+		}
+
+		return nil, next, nil
+
+	case userResourceType.Id:
+		// list and paginate through users within a domain
+		state, err := decodeDomainPageState(bag.PageToken())
+		if err != nil {
+			return nil, "", err
+		}
+
+		users, nextUsersCursor, err := client.ListUsers(ctx, state.DomainID, state.Cursor)
+		if err != nil {
+			return nil, "", wrapError(err, "newrelic-connector: failed to list users")
+		}
+
+		c, err := state.next(nextUsersCursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		next, err := bag.NextToken(c)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return users, next, nil
+
+	case legacyUsersState:
+		// list and paginate through users of organization without domains
+		users, nextCursor, err := client.ListUsers(ctx, "", bag.PageToken())
+		if err != nil {
+			return nil, "", wrapError(err, "newrelic-connector: failed to list users")
+		}
+
+		next, err := bag.NextToken(nextCursor)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return users, next, nil
+
+	default:
+		return nil, "", fmt.Errorf("invalid resource type: %s", bag.ResourceTypeID())
+	}
+}
+
+// userResources creates resources for users not listed yet.
+func (u *userBuilder) userResources(ctx context.Context, parentResourceID *v2.ResourceId, users []newrelic.User) ([]*v2.Resource, error) {
 	var rv []*v2.Resource
 	for _, user := range users {
+		if !u.markSeen(user.ID) {
+			continue
+		}
+
 		userCopy := user
 		ur, err := userResource(ctx, parentResourceID, &userCopy)
 		if err != nil {
-			return nil, "", nil, err
+			return nil, err
 		}
 
 		rv = append(rv, ur)
 	}
 
-	return rv, next, nil, nil
+	return rv, nil
+}
+
+func (u *userBuilder) resetSeen() {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	u.seen = make(map[string]struct{})
+}
+
+// markSeen records the user as listed, returns false if it was listed before.
+func (u *userBuilder) markSeen(userId string) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	if u.seen == nil {
+		u.seen = make(map[string]struct{})
+	}
+
+	if _, ok := u.seen[userId]; ok {
+		return false
+	}
+
+	u.seen[userId] = struct{}{}
+	return true
 }
 
 // Entitlements always returns an empty slice for users.
@@ -116,9 +316,166 @@ func (u *userBuilder) Grants(ctx context.Context, resource *v2.Resource, pToken
 	return nil, "", nil, nil
 }
 
-func newUserBuilder(client *newrelic.Client) *userBuilder {
+// CreateAccount creates a new user in the authentication domain set in the profile (`domain_id`),
+// domain can be omitted if organization has only one. User type can be set by `user_type` profile field.
+func (u *userBuilder) CreateAccount(
+	ctx context.Context,
+	accountInfo *v2.AccountInfo,
+	credentialOptions *v2.CredentialOptions,
+) (connectorbuilder.CreateAccountResponse, []*v2.PlaintextData, annotations.Annotations, error) {
+	name, ok := resource.GetProfileStringValue(accountInfo.Profile, "name")
+	if !ok || name == "" {
+		firstName, _ := resource.GetProfileStringValue(accountInfo.Profile, "first_name")
+		lastName, _ := resource.GetProfileStringValue(accountInfo.Profile, "last_name")
+		name = strings.TrimSpace(fmt.Sprintf("%s %s", firstName, lastName))
+	}
+
+	domainId, _ := resource.GetProfileStringValue(accountInfo.Profile, "domain_id")
+
+	ur, err := u.createUser(ctx, accountEmail(accountInfo), name, domainId, accountInfo.Profile)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return &v2.CreateAccountResponse_SuccessResult{
+		Resource:              ur,
+		IsCreateAccountResult: true,
+	}, nil, nil, nil
+}
+
+// Create creates a new user from the user resource, the same way as CreateAccount does. Authentication domain
+// is taken from the parent resource or `domain_id` profile field, user type from `user_type` profile field.
+func (u *userBuilder) Create(ctx context.Context, r *v2.Resource) (*v2.Resource, annotations.Annotations, error) {
+	trait, err := resource.GetUserTrait(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var email string
+	for _, e := range trait.Emails {
+		if email == "" || e.IsPrimary {
+			email = e.Address
+		}
+	}
+
+	domainId, _ := resource.GetProfileStringValue(trait.Profile, "domain_id")
+	if r.ParentResourceId != nil && r.ParentResourceId.ResourceType == domainResourceType.Id {
+		domainId = r.ParentResourceId.Resource
+	}
+
+	ur, err := u.createUser(ctx, email, r.DisplayName, domainId, trait.Profile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ur, nil, nil
+}
+
+// createUser creates the user for both CreateAccount and Create, so the same request creates the same user.
+// User type is taken from `user_type` field of the profile, users are basic if it's not set.
+func (u *userBuilder) createUser(ctx context.Context, email, name, domainId string, profile *structpb.Struct) (*v2.Resource, error) {
+	if email == "" {
+		return nil, status.Error(codes.InvalidArgument, "newrelic-connector: email is required to create user")
+	}
+
+	if name == "" {
+		name = email
+	}
+
+	var tier string
+	if userType, ok := resource.GetProfileStringValue(profile, "user_type"); ok && userType != "" {
+		tier, ok = lookupUserTier(userType)
+		if !ok {
+			return nil, status.Errorf(codes.InvalidArgument, "newrelic-connector: invalid user type: %s", userType)
+		}
+	}
+
+	domainId, err := u.targetDomain(ctx, domainId)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := u.client.CreateUser(ctx, domainId, email, name, tier)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to create user")
+	}
+
+	return userResource(ctx, nil, user)
+}
+
+// targetDomain returns authentication domain for the new user, falls back to the only domain of the organization.
+func (u *userBuilder) targetDomain(ctx context.Context, domainId string) (string, error) {
+	if domainId != "" {
+		return domainId, nil
+	}
+
+	domains, nextCursor, err := u.client.ListDomains(ctx, "")
+	if err != nil {
+		return "", wrapError(err, "newrelic-connector: failed to list domains")
+	}
+
+	if len(domains) != 1 || nextCursor != "" {
+		return "", status.Error(codes.InvalidArgument, "newrelic-connector: domain_id is required when organization does not have exactly one authentication domain")
+	}
+
+	return domains[0].ID, nil
+}
+
+// Delete deletes the user from the organization. The user who owns the API key used by the connector
+// is never deleted, since the connector would lose access to NerdGraph.
+func (u *userBuilder) Delete(ctx context.Context, resourceId *v2.ResourceId) (annotations.Annotations, error) {
+	if resourceId.ResourceType != userResourceType.Id {
+		return nil, fmt.Errorf("newrelic-connector: invalid resource type: %s", resourceId.ResourceType)
+	}
+
+	owner, err := u.client.GetCurrentUser(ctx)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to get owner of the API key")
+	}
+
+	if strconv.Itoa(owner.ID) == resourceId.Resource {
+		return nil, status.Errorf(
+			codes.FailedPrecondition,
+			"newrelic-connector: refusing to delete user %s, the user owns the API key used by the connector",
+			resourceId.Resource,
+		)
+	}
+
+	err = u.client.DeleteUser(ctx, resourceId.Resource)
+	if err != nil {
+		return nil, wrapError(err, "newrelic-connector: failed to delete user")
+	}
+
+	return nil, nil
+}
+
+// accountEmail returns primary email of the account, falls back to login.
+func accountEmail(accountInfo *v2.AccountInfo) string {
+	for _, e := range accountInfo.Emails {
+		if e.IsPrimary {
+			return e.Address
+		}
+	}
+
+	if len(accountInfo.Emails) > 0 {
+		return accountInfo.Emails[0].Address
+	}
+
+	if email, ok := resource.GetProfileStringValue(accountInfo.Profile, "email"); ok && email != "" {
+		return email
+	}
+
+	if strings.Contains(accountInfo.Login, "@") {
+		return accountInfo.Login
+	}
+
+	return ""
+}
+
+func newUserBuilder(client *newrelic.Client, tiers *userTierIndex) *userBuilder {
 	return &userBuilder{
 		resourceType: userResourceType,
 		client:       client,
+		tiers:        tiers,
 	}
 }
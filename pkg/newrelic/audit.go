@@ -0,0 +1,69 @@
+package newrelic
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// AuditEvent is a single NrAuditEvent recorded by NewRelic for changes made to the account
+// (see more here: https://docs.newrelic.com/docs/data-apis/understand-data/event-data/query-account-audit-logs-nrauditevent).
+type AuditEvent struct {
+	ID string
+	// Timestamp is the time of the event in epoch milliseconds.
+	Timestamp        int64
+	AccountID        int
+	ActionIdentifier string
+	ActorID          string
+	ActorEmail       string
+	ActorType        string
+	TargetID         string
+	TargetType       string
+	Description      string
+	// Attributes holds all attributes of the event, including the ones above.
+	Attributes map[string]interface{}
+}
+
+// Attribute returns string value of the event attribute, empty if the attribute is missing.
+func (e AuditEvent) Attribute(name string) string {
+	return attributeString(e.Attributes[name])
+}
+
+// newAuditEvent converts a row of NRQL results into audit event.
+func newAuditEvent(accountId int, row map[string]interface{}) AuditEvent {
+	event := AuditEvent{
+		AccountID:        accountId,
+		ActionIdentifier: attributeString(row["actionIdentifier"]),
+		ActorID:          attributeString(row["actorId"]),
+		ActorEmail:       attributeString(row["actorEmail"]),
+		ActorType:        attributeString(row["actorType"]),
+		TargetID:         attributeString(row["targetId"]),
+		TargetType:       attributeString(row["targetType"]),
+		Description:      attributeString(row["description"]),
+		Attributes:       row,
+	}
+
+	if ts, ok := row["timestamp"].(float64); ok {
+		event.Timestamp = int64(ts)
+	}
+
+	event.ID = attributeString(row["id"])
+	if event.ID == "" {
+		event.ID = fmt.Sprintf("%d-%d-%s-%s", accountId, event.Timestamp, event.ActionIdentifier, event.TargetID)
+	}
+
+	return event
+}
+
+// attributeString formats NRQL attribute value, numeric ids are returned without exponent.
+func attributeString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,60 @@
+package newrelic
+
+import (
+	"sync"
+	"time"
+)
+
+const defaultDomainCacheTTL = 5 * time.Minute
+
+type domainsPage struct {
+	domains    []Domain
+	nextCursor string
+	fetchedAt  time.Time
+}
+
+// domainCache keeps pages of authentication domains keyed by cursor, so domains are not fetched
+// again by every resource builder walking through them during a sync.
+type domainCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	pages map[string]domainsPage
+}
+
+func (dc *domainCache) get(cursor string) ([]Domain, string, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	page, ok := dc.pages[cursor]
+	if !ok || time.Since(page.fetchedAt) > dc.ttl {
+		return nil, "", false
+	}
+
+	return append([]Domain(nil), page.domains...), page.nextCursor, true
+}
+
+func (dc *domainCache) set(cursor string, domains []Domain, nextCursor string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if dc.ttl <= 0 {
+		return
+	}
+
+	if dc.pages == nil {
+		dc.pages = make(map[string]domainsPage)
+	}
+
+	dc.pages[cursor] = domainsPage{
+		domains:    append([]Domain(nil), domains...),
+		nextCursor: nextCursor,
+		fetchedAt:  time.Now(),
+	}
+}
+
+func (dc *domainCache) invalidate() {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	dc.pages = nil
+}
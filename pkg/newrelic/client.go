@@ -5,146 +5,252 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
 )
 
 const (
 	BaseHost        = "api.newrelic.com"
+	EUBaseHost      = "api.eu.newrelic.com"
 	GraphQHEndpoint = "/graphql"
+
+	RegionUS = "us"
+	RegionEU = "eu"
 )
 
 type Client struct {
-	AccountId  int
 	httpClient *http.Client
 	apikey     string
 	baseURL    *url.URL
+	maxRetries int
+	limiter    *rate.Limiter
+	rateLimit  rateLimitTracker
+	domains    domainCache
 }
 
-func NewClient(ctx context.Context, httpClient *http.Client, apikey string) (*Client, error) {
-	u := &url.URL{
-		Scheme: "https",
-		Host:   BaseHost,
-		Path:   GraphQHEndpoint,
-	}
+type Option func(*Client) error
 
-	var accId int
-	var err error
-	if httpClient != nil {
-		accId, err = GetAccountId(ctx, httpClient, u.String(), apikey)
-		if err != nil {
-			return nil, err
-		}
+// WithMaxRetries sets how many times throttled request is retried before giving up.
+func WithMaxRetries(maxRetries int) Option {
+	return func(c *Client) error {
+		c.maxRetries = maxRetries
+		return nil
 	}
+}
 
-	return &Client{
-		httpClient: httpClient,
-		apikey:     apikey,
-		baseURL:    u,
-		AccountId:  accId,
-	}, nil
+// WithDomainCacheTTL sets how long listed authentication domains are reused (0 disables the cache).
+func WithDomainCacheTTL(ttl time.Duration) Option {
+	return func(c *Client) error {
+		c.domains.ttl = ttl
+		return nil
+	}
 }
 
-func GetAccountId(ctx context.Context, httpClient *http.Client, url string, apikey string) (int, error) {
-	var res AccountsResponse
-	q := composeAccountsQuery()
+// WithRequestsPerSecond caps the number of requests per second sent to NerdGraph (0 means no cap).
+func WithRequestsPerSecond(rps int) Option {
+	return func(c *Client) error {
+		if rps > 0 {
+			c.limiter = rate.NewLimiter(rate.Limit(rps), 1)
+			c.rateLimit.state.Limit = int64(rps)
+		}
 
-	body := &GraphqlBody{
-		Query: q,
+		return nil
 	}
+}
 
-	reqBody, err := json.Marshal(body)
-	if err != nil {
-		return 0, err
-	}
+// WithRegion selects NerdGraph endpoint of the datacenter region (us or eu) the organization lives in.
+func WithRegion(region string) Option {
+	return func(c *Client) error {
+		switch strings.ToLower(region) {
+		case "", RegionUS:
+			c.baseURL.Host = BaseHost
+		case RegionEU:
+			c.baseURL.Host = EUBaseHost
+		default:
+			return fmt.Errorf("unsupported region: %s", region)
+		}
 
-	req, err := http.NewRequestWithContext(
-		ctx,
-		http.MethodPost,
-		url,
-		bytes.NewReader(reqBody),
-	)
-	if err != nil {
-		return 0, err
+		return nil
 	}
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("API-Key", apikey)
+// WithBaseURL overrides NerdGraph endpoint, e.g. to point the client to a local stand-in.
+// When the URL has no path, the default graphql endpoint path is used.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) error {
+		if baseURL == "" {
+			return nil
+		}
 
-	resp, err := httpClient.Do(req)
-	if err != nil {
-		return 0, err
-	}
+		u, err := url.Parse(baseURL)
+		if err != nil {
+			return fmt.Errorf("invalid base url: %w", err)
+		}
 
-	defer resp.Body.Close()
+		if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("invalid base url: %s", baseURL)
+		}
 
-	if resp.StatusCode != http.StatusOK {
-		return 0, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		if u.Path == "" || u.Path == "/" {
+			u.Path = GraphQHEndpoint
+		}
+
+		c.baseURL = u
+		return nil
 	}
+}
 
-	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
-		return 0, fmt.Errorf("failed to decode response body: %w", err)
+func NewClient(ctx context.Context, httpClient *http.Client, apikey string, opts ...Option) (*Client, error) {
+	c := &Client{
+		httpClient: httpClient,
+		apikey:     apikey,
+		baseURL: &url.URL{
+			Scheme: "https",
+			Host:   BaseHost,
+			Path:   GraphQHEndpoint,
+		},
+		maxRetries: defaultMaxRetries,
+		limiter:    rate.NewLimiter(rate.Inf, 0),
+		domains: domainCache{
+			ttl: defaultDomainCacheTTL,
+		},
 	}
 
-	accounts := res.Data.Actor.Accounts
-	if len(accounts) == 0 {
-		return 0, fmt.Errorf("no accounts found")
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
 	}
 
-	// TODO: support multiple accounts (only available in enterprise plan)
-	return accounts[0].ID, nil
+	return c, nil
 }
 
-// ListUsers return users across whole organization.
+// RateLimit returns rate limiting state observed on requests sent by the client.
+func (c *Client) RateLimit() RateLimitState {
+	return c.rateLimit.get()
+}
+
+// ListAccounts returns all accounts the API key has access to.
+func (c *Client) ListAccounts(ctx context.Context) ([]Account, error) {
+	var res AccountsResponse
+
+	err := c.doRequest(ctx, composeAccountsQuery(), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data.Actor.Accounts, nil
+}
+
+// ListUsers returns users under specific domain. Users of organizations without
+// authentication domains are listed through legacy user search when domainId is empty.
 func (c *Client) ListUsers(ctx context.Context, domainId string, cursor string) ([]User, string, error) {
 	var (
-		res        UsersResponse
-		resV2      UsersResponseV2
-		users      []User
-		nextCursor string
-		err        error
+		res   UsersResponse
+		resV2 UsersResponseV2
+		users []User
+		err   error
 	)
 	variables := map[string]interface{}{}
 	if cursor != "" {
 		variables["userCursor"] = cursor
-		variables["domainId"] = domainId
 	}
 
-	if domainId != "" { // It has domain
-		err = c.getResponse(ctx, composeUsersQueryV2, variables, &resV2)
+	if domainId == "" { // no domains, use legacy user search
+		err = c.getResponse(ctx, composeUsersQuery, variables, &res)
 		if err != nil {
 			return nil, "", err
 		}
 
-		authenticationDomains := resV2.Data.Actor.Organization.UserManagement.AuthenticationDomains.AuthenticationDomains
-		if len(authenticationDomains) == 1 {
-			for _, domain := range authenticationDomains {
-				nextCursor = domain.Users.NextCursor
-				for _, user := range domain.Users.Users {
-					users = append(users, User{
-						Name:  user.Name,
-						Email: user.Email,
-						ID:    user.ID,
-					})
-				}
-			}
-
-			return users, nextCursor, nil
-		}
+		return res.Data.Actor.Users.Search.Users,
+			res.Data.Actor.Users.Search.NextCursor,
+			nil
 	}
 
-	// no domains or multiple domains
-	err = c.getResponse(ctx, composeUsersQuery, variables, &res)
+	variables["domainId"] = domainId
+	err = c.getResponse(ctx, composeUsersQueryV2, variables, &resV2)
 	if err != nil {
 		return nil, "", err
 	}
 
-	return res.Data.Actor.Users.Search.Users,
-		res.Data.Actor.Users.Search.NextCursor,
-		nil
+	authenticationDomains := resV2.Data.Actor.Organization.UserManagement.AuthenticationDomains.AuthenticationDomains
+	if len(authenticationDomains) == 0 {
+		return nil, "", fmt.Errorf("domain not found: %s", domainId)
+	}
+
+	if len(authenticationDomains) > 1 {
+		return nil, "", fmt.Errorf("invalid id(%s) or cursor(%s), found more domains", domainId, cursor)
+	}
+
+	domain := authenticationDomains[0]
+	for _, user := range domain.Users.Users {
+		users = append(users, User{
+			Name:     user.Name,
+			Email:    user.Email,
+			ID:       user.ID,
+			DomainID: domainId,
+			Type:     user.Type,
+
+			EmailVerificationState: user.EmailVerificationState,
+			LastActive:             user.LastActive,
+			TimeZone:               user.TimeZone,
+		})
+	}
+
+	return users, domain.Users.NextCursor, nil
 }
 
-func (c *Client) getResponse(ctx context.Context, query func() string, variables map[string]interface{}, res interface{}) error {
+// GetUser returns the user under specific domain.
+func (c *Client) GetUser(ctx context.Context, domainId, userId string) (*User, error) {
+	var res UsersResponseV2
+	variables := map[string]interface{}{
+		"domainId": domainId,
+		"userId":   userId,
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeUserQuery(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	authenticationDomains := res.Data.Actor.Organization.UserManagement.AuthenticationDomains.AuthenticationDomains
+	if len(authenticationDomains) == 0 {
+		return nil, fmt.Errorf("%w: domain %s", ErrNotFound, domainId)
+	}
+
+	for _, user := range authenticationDomains[0].Users.Users {
+		if user.ID != userId {
+			continue
+		}
+
+		return &User{
+			Name:     user.Name,
+			Email:    user.Email,
+			ID:       user.ID,
+			DomainID: domainId,
+			Type:     user.Type,
+
+			EmailVerificationState: user.EmailVerificationState,
+			LastActive:             user.LastActive,
+			TimeZone:               user.TimeZone,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("%w: user %s", ErrNotFound, userId)
+}
+
+func (c *Client) getResponse(ctx context.Context, query func() operation, variables map[string]interface{}, res interface{}) error {
 	err := c.doRequest(
 		ctx,
 		query(),
@@ -167,6 +273,105 @@ func (c *Client) GetOrg(ctx context.Context) (*Org, error) {
 	return &res.Data.Actor.Organization, nil
 }
 
+// GetCurrentUser returns the user who owns the API key used by the client.
+func (c *Client) GetCurrentUser(ctx context.Context) (*CurrentUser, error) {
+	var res CurrentUserResponse
+
+	err := c.doRequest(ctx, composeCurrentUserQuery(), nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res.Data.Actor.User, nil
+}
+
+// ListAPIKeys returns user and ingest keys visible to the owner of the API key used by the client.
+func (c *Client) ListAPIKeys(ctx context.Context, cursor string) ([]APIKey, string, error) {
+	var res APIKeysResponse
+	variables := map[string]interface{}{}
+
+	if cursor != "" {
+		variables["keyCursor"] = cursor
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeAPIKeysQuery(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return res.Data.Actor.APIAccess.KeySearch.Keys,
+		res.Data.Actor.APIAccess.KeySearch.NextCursor,
+		nil
+}
+
+// DeleteAPIKey deletes the key of specified type.
+func (c *Client) DeleteAPIKey(ctx context.Context, keyId, keyType string) error {
+	var res DeleteAPIKeysResponse
+	variables := map[string]interface{}{}
+
+	switch keyType {
+	case APIKeyTypeUser:
+		variables["userKeyIds"] = []string{keyId}
+	case APIKeyTypeIngest:
+		variables["ingestKeyIds"] = []string{keyId}
+	default:
+		return fmt.Errorf("unsupported key type %s: %w", keyType, ErrValidation)
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeDeleteAPIKeysMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return err
+	}
+
+	if len(res.Data.MutData.Errors) > 0 {
+		return fmt.Errorf("key was not deleted: %s", res.Data.MutData.Errors[0].Message)
+	}
+
+	return nil
+}
+
+// ListAuditEvents returns audit events of the account which happened at or after the timestamp (in epoch milliseconds),
+// ordered from the oldest one. Events of the same millisecond may span pages, callers should skip the ones already read.
+func (c *Client) ListAuditEvents(ctx context.Context, accountId int, since int64, limit int) ([]AuditEvent, error) {
+	var res NrqlResponse
+	variables := map[string]interface{}{
+		"accountId": accountId,
+		"nrql": fmt.Sprintf(
+			"SELECT * FROM NrAuditEvent WHERE timestamp >= %d SINCE %d UNTIL now ORDER BY timestamp ASC LIMIT %d",
+			since,
+			since,
+			limit,
+		),
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeNrqlQuery(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]AuditEvent, 0, len(res.Data.Actor.Account.Nrql.Results))
+	for _, row := range res.Data.Actor.Account.Nrql.Results {
+		events = append(events, newAuditEvent(accountId, row))
+	}
+
+	return events, nil
+}
+
 // ListRoles returns roles across whole organization.
 func (c *Client) ListRoles(ctx context.Context, cursor string) ([]Role, string, error) {
 	var res RolesResponse
@@ -191,12 +396,104 @@ func (c *Client) ListRoles(ctx context.Context, cursor string) ([]Role, string,
 		nil
 }
 
-// ListGroupsWithRole returns groups with specified role under specified domain.
+// ListRolesPermissions returns page of permissions of each role in a single request, cursors are keyed by role id
+// and missing cursor is the first page.
+func (c *Client) ListRolesPermissions(ctx context.Context, roleIds []string, cursors map[string]string) ([]RolePermissions, error) {
+	if len(roleIds) == 0 {
+		return nil, nil
+	}
+
+	var res RolesPermissionsResponse
+	variables := make(map[string]interface{}, 2*len(roleIds))
+	for i, roleId := range roleIds {
+		variables[fmt.Sprintf("roleId%d", i)] = roleId
+
+		if cursor := cursors[roleId]; cursor != "" {
+			variables[fmt.Sprintf("permissionCursor%d", i)] = cursor
+		}
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeRolesPermissionsQuery(len(roleIds)),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := make([]RolePermissions, 0, len(roleIds))
+	for i, roleId := range roleIds {
+		page := res.Data.CustomerAdministration[fmt.Sprintf("r%d", i)]
+
+		rv = append(rv, RolePermissions{
+			RoleID:      roleId,
+			Permissions: page.Items,
+			NextCursor:  page.NextCursor,
+		})
+	}
+
+	return rv, nil
+}
+
+// CreateCustomRole creates a custom role of the organization granting specified permissions, returns id of the role.
+func (c *Client) CreateCustomRole(ctx context.Context, orgId, name, scope string, permissionIds []int) (string, error) {
+	var res CustomRoleResponse
+	variables := map[string]interface{}{
+		"orgId":         orgId,
+		"name":          name,
+		"scope":         scope,
+		"permissionIds": permissionIds,
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeCreateCustomRoleMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if res.Data.Create.ID == 0 {
+		return "", fmt.Errorf("custom role was not created: %s", name)
+	}
+
+	return strconv.Itoa(res.Data.Create.ID), nil
+}
+
+// DeleteCustomRole deletes the custom role, standard roles cannot be deleted.
+func (c *Client) DeleteCustomRole(ctx context.Context, roleId string) error {
+	id, err := strconv.Atoi(roleId)
+	if err != nil {
+		return fmt.Errorf("invalid custom role id %s: %w", roleId, ErrValidation)
+	}
+
+	var res CustomRoleResponse
+	variables := map[string]interface{}{
+		"roleId": id,
+	}
+
+	return c.doRequest(
+		ctx,
+		composeDeleteCustomRoleMutation(),
+		variables,
+		&res,
+	)
+}
+
+// ListGroupsWithRole returns groups with specified role under specified domain, all groups with grants of all roles if role is empty.
 func (c *Client) ListGroupsWithRole(ctx context.Context, domainId, roleId, cursor string) ([]Group, string, error) {
 	var res GroupsResponse
 	variables := map[string]interface{}{
 		"domainId": domainId,
-		"roleId":   roleId,
+	}
+
+	// without role filter, groups are listed with grants of all roles
+	if roleId != "" {
+		variables["roleId"] = roleId
 	}
 
 	// set variables for pagination
@@ -226,17 +523,82 @@ func (c *Client) ListGroupsWithRole(ctx context.Context, domainId, roleId, curso
 
 	groups := domains.Domains[0].Groups.Groups
 
-	return groups, domains.NextCursor, nil
+	return groups, domains.Domains[0].Groups.NextCursor, nil
+}
+
+// ListGroupRoleGrants returns access grants of specified role (or all roles if empty) to specified group.
+func (c *Client) ListGroupRoleGrants(ctx context.Context, domainId, groupId, roleId, cursor string) ([]RoleGrant, string, error) {
+	var res GroupsResponse
+	variables := map[string]interface{}{
+		"domainId": domainId,
+		"groupId":  groupId,
+	}
+
+	if roleId != "" {
+		variables["roleId"] = roleId
+	}
+
+	if cursor != "" {
+		variables["roleCursor"] = cursor
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeGroupRoleGrantsQuery(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, "", err
+	}
+
+	domains := res.Data.Actor.Organization.Management.Domains
+	if len(domains.Domains) == 0 {
+		return nil, "", fmt.Errorf("domain not found: %s", domainId)
+	}
+
+	groups := domains.Domains[0].Groups.Groups
+	if len(groups) == 0 {
+		return nil, "", fmt.Errorf("group not found: %s", groupId)
+	}
+
+	return groups[0].Roles.Roles, groups[0].Roles.NextCursor, nil
 }
 
 // ListDomains returns all authentication domains across organization.
+// Pages of domains are cached, see WithDomainCacheTTL and InvalidateDomains.
 func (c *Client) ListDomains(ctx context.Context, cursor string) ([]Domain, string, error) {
-	var res OrgAuthManagementResponse[struct {
-		ID     string `json:"id"`
-		Name   string `json:"name"`
+	if domains, nextCursor, ok := c.domains.get(cursor); ok {
+		return domains, nextCursor, nil
+	}
+
+	domains, nextCursor, err := c.listDomains(ctx, cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	c.domains.set(cursor, domains, nextCursor)
+
+	return domains, nextCursor, nil
+}
+
+// InvalidateDomains drops cached domains, e.g. when users or groups were added to or removed from domains.
+func (c *Client) InvalidateDomains() {
+	c.domains.invalidate()
+}
+
+func (c *Client) listDomains(ctx context.Context, cursor string) ([]Domain, string, error) {
+	var res OrgUserManagementResponse[struct {
+		ID                 string `json:"id"`
+		Name               string `json:"name"`
+		ProvisioningType   string `json:"provisioningType"`
+		AuthenticationType string `json:"authenticationType"`
+		Users              struct {
+			Total int `json:"totalCount"`
+		} `json:"users"`
 		Groups struct {
 			Total int `json:"totalCount"`
-		}
+		} `json:"groups"`
 	}]
 	variables := map[string]interface{}{}
 
@@ -260,9 +622,12 @@ func (c *Client) ListDomains(ctx context.Context, cursor string) ([]Domain, stri
 	domains := res.Data.Actor.Organization.Management.Domains.Domains
 	for _, d := range domains {
 		domain := Domain{
-			ID:    d.ID,
-			Name:  d.Name,
-			Total: d.Groups.Total,
+			ID:                 d.ID,
+			Name:               d.Name,
+			Total:              d.Groups.Total,
+			UsersTotal:         d.Users.Total,
+			ProvisioningType:   d.ProvisioningType,
+			AuthenticationType: d.AuthenticationType,
 		}
 
 		ad = append(
@@ -307,7 +672,7 @@ func (c *Client) ListGroups(ctx context.Context, domainId, cursor string) ([]Gro
 
 	groups := domains.Domains[0].Groups.Groups
 
-	return groups, domains.NextCursor, nil
+	return groups, domains.Domains[0].Groups.NextCursor, nil
 }
 
 // ListGroupMembers returns users under specific group.
@@ -363,6 +728,103 @@ func (c *Client) ListGroupMembers(ctx context.Context, domainId, groupId, cursor
 	return users, domains.Domains[0].Groups.Groups[0].Users.NextCursor, nil
 }
 
+// IsGroupMember reports whether the user is a member of the group under specified domain.
+func (c *Client) IsGroupMember(ctx context.Context, domainId, groupId, userId string) (bool, error) {
+	var res GroupMembersResponse
+	err := c.doRequest(
+		ctx,
+		composeGroupMemberQuery(),
+		map[string]interface{}{
+			"domainId": domainId,
+			"groupId":  groupId,
+			"userId":   userId,
+		},
+		&res,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	domains := res.Data.Actor.Organization.Management.Domains.Domains
+	if len(domains) == 0 {
+		return false, fmt.Errorf("domain not found: %s", domainId)
+	}
+
+	if len(domains[0].Groups.Groups) == 0 {
+		return false, fmt.Errorf("group not found: %s", groupId)
+	}
+
+	for _, u := range domains[0].Groups.Groups[0].Users.Users {
+		if u.ID == userId {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// ListGroupsMembers returns a page of members for each of the groups under specified domain in a single request,
+// cursors map group id to the cursor of members page, missing cursor means the first page.
+// Groups which are not found are reported by Err of their page instead of failing the whole request.
+func (c *Client) ListGroupsMembers(ctx context.Context, domainId string, groupIds []string, cursors map[string]string) ([]GroupMembers, error) {
+	if len(groupIds) == 0 {
+		return nil, nil
+	}
+
+	var res GroupsMembersResponse
+	variables := map[string]interface{}{
+		"domainId": domainId,
+	}
+
+	for i, groupId := range groupIds {
+		variables[fmt.Sprintf("groupId%d", i)] = groupId
+
+		if cursor := cursors[groupId]; cursor != "" {
+			variables[fmt.Sprintf("membersCursor%d", i)] = cursor
+		}
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeGroupsMembersQuery(len(groupIds)),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := res.Data.Actor.Organization.Management.Domains
+	if len(domains.Domains) == 0 {
+		return nil, fmt.Errorf("domain not found: %s", domainId)
+	}
+
+	rv := make([]GroupMembers, 0, len(groupIds))
+	for i, groupId := range groupIds {
+		groups := domains.Domains[0][fmt.Sprintf("g%d", i)].Groups
+		if len(groups) == 0 {
+			rv = append(rv, GroupMembers{
+				GroupID: groupId,
+				Err:     fmt.Errorf("%w: group %s", ErrNotFound, groupId),
+			})
+			continue
+		}
+
+		members := GroupMembers{
+			GroupID:    groupId,
+			NextCursor: groups[0].Users.NextCursor,
+		}
+
+		for _, u := range groups[0].Users.Users {
+			members.Members = append(members.Members, u.ID)
+		}
+
+		rv = append(rv, members)
+	}
+
+	return rv, nil
+}
+
 func (c *Client) AddUserToGroup(ctx context.Context, groupId, userId string) error {
 	var res AddGroupMemberResponse
 	variables := map[string]interface{}{
@@ -403,6 +865,147 @@ func (c *Client) RemoveUserFromGroup(ctx context.Context, groupId, userId string
 	return nil
 }
 
+// CreateGroup creates a new group with the display name under specified authentication domain.
+func (c *Client) CreateGroup(ctx context.Context, domainId, name string) (*Group, error) {
+	var res CreateGroupResponse
+	variables := map[string]interface{}{
+		"domainId": domainId,
+		"name":     name,
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeCreateGroupMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// totals of users or groups within domains changed
+	c.InvalidateDomains()
+
+	group := res.Data.MutData.Group
+	if group.ID == "" {
+		return nil, fmt.Errorf("group was not created: %s", name)
+	}
+
+	return &group, nil
+}
+
+// DeleteGroup deletes the group, members of the group are not deleted.
+func (c *Client) DeleteGroup(ctx context.Context, groupId string) error {
+	var res DeleteGroupResponse
+	variables := map[string]interface{}{
+		"groupId": groupId,
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeDeleteGroupMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return err
+	}
+
+	// totals of users or groups within domains changed
+	c.InvalidateDomains()
+
+	return nil
+}
+
+// CreateUser creates a new user of specified tier under specified authentication domain.
+func (c *Client) CreateUser(ctx context.Context, domainId, email, name, tier string) (*User, error) {
+	var res CreateUserResponse
+	variables := map[string]interface{}{
+		"domainId": domainId,
+		"email":    email,
+		"name":     name,
+	}
+
+	if tier != "" {
+		variables["userType"] = tier
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeCreateUserMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// totals of users or groups within domains changed
+	c.InvalidateDomains()
+
+	created := res.Data.MutData.CreatedUser
+	if created.ID == "" {
+		return nil, fmt.Errorf("user was not created: %s", email)
+	}
+
+	return &User{
+		ID:                     created.ID,
+		Email:                  created.Email,
+		Name:                   created.Name,
+		DomainID:               domainId,
+		Type:                   created.Type,
+		EmailVerificationState: EmailVerificationPending,
+	}, nil
+}
+
+// DeleteUser deletes the user from the organization.
+func (c *Client) DeleteUser(ctx context.Context, userId string) error {
+	var res DeleteUserResponse
+	variables := map[string]interface{}{
+		"userId": userId,
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeDeleteUserMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return err
+	}
+
+	// totals of users or groups within domains changed
+	c.InvalidateDomains()
+
+	if res.Data.MutData.DeletedUser.ID == "" {
+		return fmt.Errorf("user was not deleted: %s", userId)
+	}
+
+	return nil
+}
+
+// UpdateUserType changes type of the user to the specified tier.
+func (c *Client) UpdateUserType(ctx context.Context, userId, tier string) error {
+	var res UpdateUserResponse
+	variables := map[string]interface{}{
+		"userId":   userId,
+		"userType": tier,
+	}
+
+	err := c.doRequest(
+		ctx,
+		composeUpdateUserTypeMutation(),
+		variables,
+		&res,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
 func (c *Client) AddGroupRole(ctx context.Context, roleId, groupId string) error {
 	var res GrantRoleResponse
 	variables := map[string]interface{}{
@@ -525,7 +1128,45 @@ func (c *Client) RemoveOrgRole(ctx context.Context, roleId, groupId string) erro
 	return nil
 }
 
-func (c *Client) doRequest(ctx context.Context, q string, v map[string]interface{}, res interface{}) error {
+// doRequest sends the operation to NerdGraph, respecting the client-side rate limit
+// and retrying throttled requests with backoff. Variables are checked against the operation before sending.
+func (c *Client) doRequest(ctx context.Context, op operation, v map[string]interface{}, res interface{}) error {
+	if err := op.validate(v); err != nil {
+		return err
+	}
+
+	q := op.String()
+	for attempt := 0; ; attempt++ {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+
+		err := sendRequest(ctx, c.httpClient, c.baseURL.String(), c.apikey, q, v, res)
+		if err == nil {
+			c.rateLimit.ok()
+			return nil
+		}
+
+		if !isRetryable(err) {
+			return err
+		}
+
+		delay := retryDelay(attempt, err)
+		c.rateLimit.throttled(time.Now().Add(delay))
+
+		if attempt >= c.maxRetries {
+			return err
+		}
+
+		if err := wait(ctx, delay); err != nil {
+			return err
+		}
+	}
+}
+
+// sendRequest posts graphql query to NerdGraph and decodes the response into res.
+// Errors reported by NerdGraph in the response body are returned as *RequestError.
+func sendRequest(ctx context.Context, httpClient *http.Client, url, apikey, q string, v map[string]interface{}, res interface{}) error {
 	body := &GraphqlBody{
 		Query:     q,
 		Variables: v,
@@ -538,7 +1179,7 @@ func (c *Client) doRequest(ctx context.Context, q string, v map[string]interface
 	req, err := http.NewRequestWithContext(
 		ctx,
 		http.MethodPost,
-		c.baseURL.String(),
+		url,
 		bytes.NewReader(reqBody),
 	)
 	if err != nil {
@@ -546,22 +1187,49 @@ func (c *Client) doRequest(ctx context.Context, q string, v map[string]interface
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("API-Key", c.apikey)
+	req.Header.Set("API-Key", apikey)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return err
 	}
 
 	defer resp.Body.Close()
 
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	var envelope graphqlEnvelope
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+		// error responses are not guaranteed to carry graphql envelope
+		var rErr *RequestError
+		if err := json.Unmarshal(respBody, &envelope); err != nil {
+			rErr = newRequestError(resp.StatusCode, nil)
+		} else {
+			rErr = newRequestError(resp.StatusCode, &envelope)
+		}
+
+		rErr.RetryAfter = parseRetryAfter(resp.Header)
+
+		return rErr
 	}
 
-	if err := json.NewDecoder(resp.Body).Decode(res); err != nil {
+	if err := json.Unmarshal(respBody, &envelope); err != nil {
 		return fmt.Errorf("failed to decode response body: %w", err)
 	}
 
+	if err := json.Unmarshal(respBody, res); err != nil {
+		return fmt.Errorf("failed to decode response body: %w", err)
+	}
+
+	if len(envelope.Errors) > 0 {
+		rErr := newRequestError(resp.StatusCode, &envelope)
+		rErr.RetryAfter = parseRetryAfter(resp.Header)
+
+		return rErr
+	}
+
 	return nil
 }
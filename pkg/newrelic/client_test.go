@@ -0,0 +1,227 @@
+package newrelic_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+	"github.com/conductorone/baton-newrelic/pkg/newrelic/newrelictest"
+)
+
+func newTestClient(t *testing.T) (*newrelictest.Server, *newrelic.Client) {
+	t.Helper()
+
+	s := newrelictest.NewServer()
+	t.Cleanup(s.Close)
+
+	s.AddDomain(newrelictest.Domain{ID: "d1", Name: "Default"})
+	for _, u := range []newrelictest.User{
+		{ID: "1", Email: "alice@example.com", Name: "Alice", DomainID: "d1", Tier: newrelic.UserTierFull},
+		{ID: "2", Email: "bob@example.com", Name: "Bob", DomainID: "d1"},
+		{ID: "3", Email: "carol@example.com", Name: "Carol", DomainID: "d1", Tier: newrelic.UserTierCore},
+	} {
+		s.AddUser(u)
+	}
+	s.AddGroup(newrelictest.Group{ID: "g1", Name: "Admins", DomainID: "d1", Members: []string{"1", "2", "3"}})
+	s.AddGroup(newrelictest.Group{ID: "g2", Name: "Readers", DomainID: "d1"})
+	for id := 1; id <= 3; id++ {
+		s.AddPermission(newrelictest.Permission{ID: id, Name: fmt.Sprintf("Permission %d", id)})
+	}
+	s.AddRole(newrelictest.Role{ID: "10", Name: "all_product_admin", DisplayName: "All Product Admin", Scope: "account", PermissionIDs: []int{1, 2, 3}})
+	s.AddRoleGrant(newrelictest.RoleGrant{RoleID: "10", GroupID: "g1", AccountID: 100})
+
+	c, err := s.Client(context.Background(), newrelic.WithMaxRetries(0))
+	if err != nil {
+		t.Fatalf("failed to create client: %v", err)
+	}
+
+	return s, c
+}
+
+func TestListUsersPaginates(t *testing.T) {
+	_, c := newTestClient(t)
+	ctx := context.Background()
+
+	var users []newrelic.User
+	cursor := ""
+	for {
+		page, next, err := c.ListUsers(ctx, "d1", cursor)
+		if err != nil {
+			t.Fatalf("ListUsers: %v", err)
+		}
+
+		users = append(users, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	if len(users) != 3 {
+		t.Fatalf("expected 3 users, got %d", len(users))
+	}
+
+	if tier := users[0].Type.Tier(); tier != newrelic.UserTierFull {
+		t.Errorf("expected tier of %s to be %s, got %s", users[0].Email, newrelic.UserTierFull, tier)
+	}
+
+	if users[1].DomainID != "d1" {
+		t.Errorf("expected domain of %s to be d1, got %q", users[1].Email, users[1].DomainID)
+	}
+}
+
+func TestListGroupsMembers(t *testing.T) {
+	_, c := newTestClient(t)
+
+	members, err := c.ListGroupsMembers(context.Background(), "d1", []string{"g1", "gone", "g2"}, nil)
+	if err != nil {
+		t.Fatalf("ListGroupsMembers: %v", err)
+	}
+
+	if len(members) != 3 {
+		t.Fatalf("expected members of 3 groups, got %d", len(members))
+	}
+
+	for _, m := range members {
+		if m.GroupID != "gone" && m.Err != nil {
+			t.Errorf("expected members of %s, got %v", m.GroupID, m.Err)
+		}
+
+		switch m.GroupID {
+		case "gone":
+			if !errors.Is(m.Err, newrelic.ErrNotFound) {
+				t.Errorf("expected missing group to be not found, got %v", m.Err)
+			}
+		case "g1":
+			if len(m.Members) != 2 || m.NextCursor == "" {
+				t.Errorf("expected first page of 2 members of g1 with next cursor, got %v %q", m.Members, m.NextCursor)
+			}
+		case "g2":
+			if len(m.Members) != 0 || m.NextCursor != "" {
+				t.Errorf("expected no members of g2, got %v %q", m.Members, m.NextCursor)
+			}
+		}
+	}
+}
+
+func TestListRolesPermissions(t *testing.T) {
+	_, c := newTestClient(t)
+
+	pages, err := c.ListRolesPermissions(context.Background(), []string{"10", "99"}, nil)
+	if err != nil {
+		t.Fatalf("ListRolesPermissions: %v", err)
+	}
+
+	if len(pages) != 2 || pages[0].RoleID != "10" || pages[1].RoleID != "99" {
+		t.Fatalf("expected permissions of roles 10 and 99, got %+v", pages)
+	}
+
+	if len(pages[0].Permissions) != 2 || pages[0].NextCursor == "" {
+		t.Errorf("expected first page of 2 permissions of role 10 with next cursor, got %+v", pages[0])
+	}
+
+	if len(pages[1].Permissions) != 0 || pages[1].NextCursor != "" {
+		t.Errorf("expected no permissions of unknown role, got %+v", pages[1])
+	}
+
+	next, err := c.ListRolesPermissions(context.Background(), []string{"10"}, map[string]string{"10": pages[0].NextCursor})
+	if err != nil {
+		t.Fatalf("ListRolesPermissions: %v", err)
+	}
+
+	if len(next) != 1 || len(next[0].Permissions) != 1 || next[0].Permissions[0].ID != "3" || next[0].NextCursor != "" {
+		t.Errorf("expected last page with permission 3 of role 10, got %+v", next)
+	}
+}
+
+func TestListGroupRoleGrants(t *testing.T) {
+	_, c := newTestClient(t)
+
+	grants, _, err := c.ListGroupRoleGrants(context.Background(), "d1", "g1", "", "")
+	if err != nil {
+		t.Fatalf("ListGroupRoleGrants: %v", err)
+	}
+
+	if len(grants) != 1 || grants[0].RoleID != 10 || grants[0].AccountID != 100 {
+		t.Fatalf("expected grant of role 10 on account 100, got %+v", grants)
+	}
+}
+
+func TestGroupMutations(t *testing.T) {
+	s, c := newTestClient(t)
+	ctx := context.Background()
+
+	if err := c.AddUserToGroup(ctx, "g2", "1"); err != nil {
+		t.Fatalf("AddUserToGroup: %v", err)
+	}
+
+	if err := c.AddAccountRole(ctx, "10", "g2", 100); err != nil {
+		t.Fatalf("AddAccountRole: %v", err)
+	}
+
+	if members := s.Members("g2"); len(members) != 1 || members[0] != "1" {
+		t.Errorf("expected user 1 to be member of g2, got %v", members)
+	}
+
+	if grants := s.RoleGrants("g2"); len(grants) != 1 {
+		t.Errorf("expected role to be granted to g2, got %v", grants)
+	}
+
+	if err := c.RemoveUserFromGroup(ctx, "g2", "1"); err != nil {
+		t.Fatalf("RemoveUserFromGroup: %v", err)
+	}
+
+	if members := s.Members("g2"); len(members) != 0 {
+		t.Errorf("expected no members of g2, got %v", members)
+	}
+}
+
+func TestCreateAndDeleteUser(t *testing.T) {
+	s, c := newTestClient(t)
+	ctx := context.Background()
+
+	user, err := c.CreateUser(ctx, "d1", "dave@example.com", "Dave", newrelic.UserTierBasic)
+	if err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	if !user.IsPendingVerification() {
+		t.Errorf("expected new user to be pending verification")
+	}
+
+	if err := c.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+
+	if _, ok := s.User(user.ID); ok {
+		t.Errorf("expected user %s to be deleted", user.ID)
+	}
+}
+
+func TestErrorClassification(t *testing.T) {
+	s, c := newTestClient(t)
+	ctx := context.Background()
+
+	for class, want := range map[string]error{
+		"NOT_FOUND": newrelic.ErrNotFound,
+		"FORBIDDEN": newrelic.ErrForbidden,
+	} {
+		s.FailOperation("ListRoles", class)
+
+		_, _, err := c.ListRoles(ctx, "")
+		if !errors.Is(err, want) {
+			t.Errorf("expected %s to be classified as %v, got %v", class, want, err)
+		}
+	}
+
+	s.FailOperation("ListRoles", "")
+	if _, _, err := c.ListRoles(ctx, ""); err != nil {
+		t.Errorf("expected ListRoles to succeed after failure is cleared, got %v", err)
+	}
+
+	if err := c.RemoveUserFromGroup(ctx, "missing", "1"); !errors.Is(err, newrelic.ErrNotFound) {
+		t.Errorf("expected missing group to be classified as not found, got %v", err)
+	}
+}
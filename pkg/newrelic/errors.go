@@ -0,0 +1,143 @@
+package newrelic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Errors that can be matched with errors.Is against errors returned by the client.
+var (
+	ErrNotFound   = errors.New("resource not found")
+	ErrForbidden  = errors.New("access forbidden")
+	ErrValidation = errors.New("invalid request")
+	ErrThrottled  = errors.New("request throttled")
+)
+
+// NerdGraph error classes (see `extensions.errorClass` in the errors array).
+var (
+	notFoundClasses   = []string{"NOT_FOUND"}
+	forbiddenClasses  = []string{"FORBIDDEN", "UNAUTHORIZED", "ACCESS_DENIED"}
+	validationClasses = []string{"BAD_USER_INPUT", "INVALID_INPUT", "VALIDATION_ERROR", "GRAPHQL_VALIDATION_FAILED", "GRAPHQL_PARSE_FAILED"}
+	throttledClasses  = []string{"TOO_MANY_REQUESTS", "RATE_LIMITED", "RATE_LIMIT_EXCEEDED"}
+)
+
+// GraphQLError is a single entry of the `errors` array returned by NerdGraph.
+type GraphQLError struct {
+	Message    string        `json:"message"`
+	Path       []interface{} `json:"path,omitempty"`
+	Extensions struct {
+		ErrorClass string `json:"errorClass"`
+	} `json:"extensions"`
+}
+
+// graphqlEnvelope is used to decode errors and (partial) data from any NerdGraph response.
+type graphqlEnvelope struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []GraphQLError  `json:"errors"`
+}
+
+// RequestError is returned when NerdGraph rejects a request, either with
+// an unexpected status code or with a non-empty `errors` array.
+type RequestError struct {
+	StatusCode int
+	Errors     []GraphQLError
+	// Data holds the partial data returned alongside the errors, if any.
+	Data json.RawMessage
+	// RetryAfter holds the delay requested by NerdGraph in Retry-After header.
+	RetryAfter time.Duration
+
+	kind error
+}
+
+func (e *RequestError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("unexpected status code: %d", e.StatusCode)
+	}
+
+	messages := make([]string, 0, len(e.Errors))
+	for _, gqlErr := range e.Errors {
+		msg := gqlErr.Message
+		if len(gqlErr.Path) > 0 {
+			msg = fmt.Sprintf("%s (path: %s)", msg, formatPath(gqlErr.Path))
+		}
+
+		if gqlErr.Extensions.ErrorClass != "" {
+			msg = fmt.Sprintf("%s [%s]", msg, gqlErr.Extensions.ErrorClass)
+		}
+
+		messages = append(messages, msg)
+	}
+
+	return fmt.Sprintf("graphql error: %s", strings.Join(messages, "; "))
+}
+
+// Unwrap returns one of the Err* sentinel errors, if the request error could be classified.
+func (e *RequestError) Unwrap() error {
+	return e.kind
+}
+
+// HasPartialData reports whether NerdGraph returned any data alongside the errors.
+func (e *RequestError) HasPartialData() bool {
+	return len(e.Data) > 0 && string(e.Data) != "null"
+}
+
+func newRequestError(statusCode int, envelope *graphqlEnvelope) *RequestError {
+	rErr := &RequestError{
+		StatusCode: statusCode,
+	}
+
+	if envelope != nil {
+		rErr.Errors = envelope.Errors
+		rErr.Data = envelope.Data
+	}
+
+	rErr.kind = classifyError(statusCode, rErr.Errors)
+
+	return rErr
+}
+
+// classifyError maps status code and error classes to the sentinel errors.
+// Error classes take precedence since NerdGraph responds with 200 for most failures.
+func classifyError(statusCode int, gqlErrors []GraphQLError) error {
+	for _, gqlErr := range gqlErrors {
+		class := strings.ToUpper(gqlErr.Extensions.ErrorClass)
+
+		switch {
+		case slices.Contains(throttledClasses, class):
+			return ErrThrottled
+		case slices.Contains(forbiddenClasses, class):
+			return ErrForbidden
+		case slices.Contains(notFoundClasses, class):
+			return ErrNotFound
+		case slices.Contains(validationClasses, class):
+			return ErrValidation
+		}
+	}
+
+	switch statusCode {
+	case http.StatusTooManyRequests:
+		return ErrThrottled
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrForbidden
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrValidation
+	}
+
+	return nil
+}
+
+func formatPath(path []interface{}) string {
+	parts := make([]string, 0, len(path))
+	for _, p := range path {
+		parts = append(parts, fmt.Sprint(p))
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -1,349 +1,533 @@
 package newrelic
 
-import "fmt"
-
-// GraphQL queries and mutations.
-const (
-	actorBaseQ = "actor { %s }"
-
-	usersQuery = `users {
-		userSearch(cursor: $userCursor) {
-			nextCursor
-			totalCount
-			users {
-				email
-				name
-				userId
-			}
-		}
-	 }`
-
-	usersQueryV2 = `organization {
-		userManagement {
-			authenticationDomains(id: $domainId) {
-			authenticationDomains {
-			  users(cursor: $userCursor) {
-				users {
-				  email
-				  id
-				  name
-				  emailVerificationState
-				}
-				nextCursor
-				totalCount
-			  }
-			}
-		  }
-		}
-	  }`
-
-	accountsQuery = `accounts {
-		id
-	}`
-
-	orgQuery = `organization { %s }`
-
-	managementQuery = `organization { authorizationManagement { %s } }`
-
-	orgDetailQuery = `organization { 
-		id
-		name
-	}`
-
-	rolesQuery = `roles(cursor: $roleCursor) {
-		nextCursor
-		totalCount
-		roles {
-			id
-			displayName
-			name
-			scope
-		}
-	}`
-
-	groupsQuery = `authenticationDomains(id: $domainId) {
-		authenticationDomains {
-			id
-			name
-			groups(cursor: $groupCursor) {
-				nextCursor
-				totalCount
-				groups {
-					id
-					displayName
-					roles {
-						totalCount
-					}
-				}
-			}
-		}
-	}`
-
-	groupRolesQuery = `authenticationDomains(id: $domainId) {
-		nextCursor
-		totalCount
-		authenticationDomains {
-			id
-			name
-			groups(cursor: $groupCursor) {
-				nextCursor
-				totalCount
-				groups {
-					id
-					displayName
-					roles(roleId: $roleId) {
-						nextCursor
-						totalCount
-						roles {
-							id
-							name
-							displayName
-						}
-					}
-				}
-			}
-		}
-	}`
-
-	domainsQuery = `authenticationDomains(cursor: $cursor) {
-		nextCursor
-		totalCount
-		authenticationDomains {
-			id
-			name
-			groups {
-				totalCount
-			}
-		}
-	}`
-
-	groupMembersQuery = `userManagement {
-		authenticationDomains(id: $domainId) {
-			authenticationDomains {
-				groups(id: $groupId) {
-					nextCursor
-					totalCount
-					groups {
-						id
-						displayName
-						users(cursor: $membersCursor) {
-							nextCursor
-							totalCount
-							users {
-								id
-							}
-						}
-					}
-				}
-			}
-		}
-	}`
-
-	addGroupMemberMutation = `userManagementAddUsersToGroups(
-		addUsersToGroupsOptions: {
-			groupIds: [$groupId]
-			userIds: [$userId]
-		}
-	) {
-		groups {
-			displayName
-			id
-		}
-	}`
-
-	removeGroupMemberMutation = `userManagementRemoveUsersFromGroups(
-		removeUsersFromGroupsOptions: {
-			groupIds: [$groupId]
-			userIds: [$userId]
-		}
-	) {
-		groups {
-			displayName
-			id
-		}
-	}`
-
-	addRoleMutation = `authorizationManagementGrantAccess(
-		grantAccessOptions: {
-			groupId: $groupId 
-		 	%s
-		}
-	) {
-		roles {
-			displayName
-			roleId
-		}
-	}`
-
-	groupAccessGrants = `groupAccessGrants: {
-		groupId: $groupId
-		roleId: $roleId
-	}`
-
-	accountAccessGrants = `accountAccessGrants: {
-		accountId: $accountId
-		roleId: $roleId
-	}`
-
-	orgAccessGrants = `organizationAccessGrants: {
-		roleId: $roleId
-	}`
-
-	removeRoleMutation = `authorizationManagementRevokeAccess(
-		revokeAccessOptions: {
-			groupId: $groupId
-			%s
-		}
-	) {
-		roles {
-			displayName
-			roleId
-		}
-	}`
+import (
+	"fmt"
 )
 
+// Variables of GraphQL operations, shared by all operations using them.
 var (
-	ManagementsQ = fmt.Sprintf(actorBaseQ, managementQuery)
-	OrgQ         = fmt.Sprintf(actorBaseQ, orgQuery)
-	AccountsQ    = fmt.Sprintf(actorBaseQ, accountsQuery)
-
-	UsersQ     = fmt.Sprintf(actorBaseQ, usersQuery)
-	UsersQV2   = fmt.Sprintf(actorBaseQ, usersQueryV2)
-	OrgDetailQ = fmt.Sprintf(actorBaseQ, orgDetailQuery)
-
-	RolesQ        = fmt.Sprintf(ManagementsQ, rolesQuery)
-	GroupsQ       = fmt.Sprintf(ManagementsQ, groupsQuery)
-	GroupRolesQ   = fmt.Sprintf(ManagementsQ, groupRolesQuery)
-	DomainsQ      = fmt.Sprintf(ManagementsQ, domainsQuery)
-	GroupMembersQ = fmt.Sprintf(OrgQ, groupMembersQuery)
-
-	AddGroupRole   = fmt.Sprintf(addRoleMutation, groupAccessGrants)
-	AddAccountRole = fmt.Sprintf(addRoleMutation, accountAccessGrants)
-	AddOrgRole     = fmt.Sprintf(addRoleMutation, orgAccessGrants)
-
-	RemoveGroupRole   = fmt.Sprintf(removeRoleMutation, groupAccessGrants)
-	RemoveAccountRole = fmt.Sprintf(removeRoleMutation, accountAccessGrants)
-	RemoveOrgRole     = fmt.Sprintf(removeRoleMutation, orgAccessGrants)
+	userCursorVar       = variable{"userCursor", "String"}
+	keyCursorVar        = variable{"keyCursor", "String"}
+	roleCursorVar       = variable{"roleCursor", "String"}
+	permissionCursorVar = variable{"permissionCursor", "String"}
+	domainCursorVar     = variable{"cursor", "String"}
+	groupCursorVar      = variable{"groupCursor", "String"}
+	membersCursorVar    = variable{"membersCursor", "String"}
+
+	// filters of authorization and user management queries take lists of ids
+	domainIdsVar = variable{"domainId", "[ID!]"}
+	groupIdsVar  = variable{"groupId", "[ID!]"}
+	roleIdsVar   = variable{"roleId", "[ID!]"}
+	userIdsVar   = variable{"userId", "[ID!]"}
+
+	accountIdVar     = variable{"accountId", "Int!"}
+	nrqlVar          = variable{"nrql", "Nrql!"}
+	permRoleIdVar    = variable{"roleId", "ID!"}
+	domainIdVar      = variable{"domainId", "ID!"}
+	groupIdVar       = variable{"groupId", "ID!"}
+	userIdVar        = variable{"userId", "ID!"}
+	roleIdVar        = variable{"roleId", "ID!"}
+	nameVar          = variable{"name", "String!"}
+	emailVar         = variable{"email", "String!"}
+	orgIdVar         = variable{"orgId", "ID!"}
+	customRoleIdVar  = variable{"roleId", "Int!"}
+	permissionIdsVar = variable{"permissionIds", "[Int]!"}
+	scopeVar         = variable{"scope", "String!"}
+	userKeyIdsVar    = variable{"userKeyIds", "[ID!]"}
+	ingestKeyIdsVar  = variable{"ingestKeyIds", "[ID!]"}
+	userTierVar      = variable{"userType", "UserManagementRequestedTierName"}
+	newUserTierVar   = variable{"userType", "UserManagementRequestedTierName!"}
 )
 
-func composeAccountsQuery() string {
-	return fmt.Sprintf(
-		`query ListAccounts {
-			%s
-		}`, AccountsQ)
+// Selections shared by several operations.
+var (
+	pageFields = scalars{"nextCursor", "totalCount"}
+
+	userTypeField = fld("type", scalars{"displayName", "id"})
+
+	roleGrantFields = scalars{"id", "roleId", "name", "displayName", "accountId", "organizationId", "type"}
+
+	groupMembersFields = fld("users",
+		pageFields,
+		fld("users", scalars{"id"}),
+	)
+
+	accessRolesFields = fld("roles", scalars{"displayName", "roleId"})
+)
+
+// actor wraps the selection into the actor root field.
+func actor(selection ...selector) field {
+	return fld("actor", selection...)
+}
+
+// organization wraps the selection into the organization of the actor.
+func organization(selection ...selector) field {
+	return actor(fld("organization", selection...))
+}
+
+// authorizationDomains selects authentication domains filtered by $domainId from authorization management.
+func authorizationDomains(selection ...selector) field {
+	return organization(
+		fld("authorizationManagement",
+			fld("authenticationDomains", selection...).args(arg("id", domainIdsVar)),
+		),
+	)
+}
+
+// userManagementDomains selects authentication domains filtered by $domainId from user management.
+func userManagementDomains(selection ...selector) field {
+	return organization(
+		fld("userManagement",
+			fld("authenticationDomains",
+				fld("authenticationDomains", selection...),
+			).args(arg("id", domainIdsVar)),
+		),
+	)
+}
+
+func composeAccountsQuery() operation {
+	return query("ListAccounts",
+		actor(fld("accounts", scalars{"id", "name"})),
+	)
 }
 
 // https://docs.newrelic.com/docs/apis/nerdgraph/examples/nerdgraph-manage-users/
-func composeUsersQueryV2() string {
-	return fmt.Sprintf(
-		`query ListUsers($userCursor: String, $domainId: [ID!]) {
-			%s
-		}`, UsersQV2)
+func composeUsersQueryV2() operation {
+	return query("ListUsers",
+		userManagementDomains(
+			fld("users",
+				fld("users",
+					scalars{"email", "id", "name", "emailVerificationState", "lastActive", "timeZone"},
+					userTypeField,
+				),
+				pageFields,
+			).args(arg("cursor", userCursorVar)),
+		),
+	)
+}
+
+// composeUserQuery composes query for a single user under the domain.
+func composeUserQuery() operation {
+	return query("GetUser",
+		userManagementDomains(
+			fld("users",
+				fld("users",
+					scalars{"email", "id", "name", "emailVerificationState", "lastActive", "timeZone"},
+					userTypeField,
+				),
+			).args(arg("id", userIdsVar)),
+		),
+	)
+}
+
+func composeUsersQuery() operation {
+	return query("SearchUsers",
+		actor(
+			fld("users",
+				fld("userSearch",
+					pageFields,
+					fld("users", scalars{"email", "name", "userId"}),
+				).args(arg("cursor", userCursorVar)),
+			),
+		),
+	)
+}
+
+func composeOrgQuery() operation {
+	return query("GetOrg",
+		organization(scalars{"id", "name"}),
+	)
+}
+
+func composeCurrentUserQuery() operation {
+	return query("GetCurrentUser",
+		actor(fld("user", scalars{"id", "email", "name"})),
+	)
+}
+
+// https://docs.newrelic.com/docs/apis/nerdgraph/examples/use-nerdgraph-manage-license-keys-user-keys/
+func composeAPIKeysQuery() operation {
+	return query("ListAPIKeys",
+		actor(
+			fld("apiAccess",
+				// key search reports count of keys instead of totalCount
+				fld("keySearch",
+					scalars{"nextCursor"},
+					fld("keys",
+						scalars{"id", "name", "type", "createdAt"},
+						on("ApiAccessUserKey",
+							scalars{"accountId"},
+							fld("user", scalars{"id", "email", "name"}),
+						),
+						on("ApiAccessIngestKey", scalars{"accountId", "ingestType"}),
+					),
+				).args(
+					arg("query", object{arg("types", list{enum(APIKeyTypeUser), enum(APIKeyTypeIngest)})}),
+					arg("cursor", keyCursorVar),
+				),
+			),
+		),
+	)
+}
+
+// https://docs.newrelic.com/docs/apis/nerdgraph/examples/nerdgraph-nrql-tutorial/
+func composeNrqlQuery() operation {
+	return query("RunNrql",
+		actor(
+			fld("account",
+				fld("nrql", scalars{"results"}).args(arg("query", nrqlVar)),
+			).args(arg("id", accountIdVar)),
+		),
+	)
+}
+
+func composeRolesQuery() operation {
+	return query("ListRoles",
+		organization(
+			fld("authorizationManagement",
+				fld("roles",
+					pageFields,
+					fld("roles", scalars{"id", "displayName", "name", "scope", "type"}),
+				).args(arg("cursor", roleCursorVar)),
+			),
+		),
+	)
+}
+
+// https://docs.newrelic.com/docs/apis/nerdgraph/examples/nerdgraph-custom-roles/
+// composeRolesPermissionsQuery composes query for permissions of n roles, each role under its own alias.
+func composeRolesPermissionsQuery(n int) operation {
+	aliases := make([]selector, 0, n)
+	for i := 0; i < n; i++ {
+		roleId := variable{fmt.Sprintf("roleId%d", i), permRoleIdVar.typ}
+		permissionCursor := variable{fmt.Sprintf("permissionCursor%d", i), permissionCursorVar.typ}
+
+		aliases = append(aliases,
+			fld("permissions",
+				scalars{"nextCursor"},
+				fld("items", scalars{"id", "name", "feature", "category"}),
+			).args(
+				arg("filter", object{arg("roleId", object{arg("eq", roleId)})}),
+				arg("cursor", permissionCursor),
+			).as(fmt.Sprintf("r%d", i)),
+		)
+	}
+
+	return query("ListRolesPermissions", fld("customerAdministration", aliases...))
+}
+
+func composeDomainsQuery() operation {
+	return query("ListDomains",
+		organization(
+			fld("userManagement",
+				fld("authenticationDomains",
+					pageFields,
+					fld("authenticationDomains",
+						scalars{"id", "name", "provisioningType", "authenticationType"},
+						fld("users", scalars{"totalCount"}),
+						fld("groups", scalars{"totalCount"}),
+					),
+				).args(arg("cursor", domainCursorVar)),
+			),
+		),
+	)
+}
+
+func composeGroupsQuery() operation {
+	return query("ListGroups",
+		authorizationDomains(
+			fld("authenticationDomains",
+				scalars{"id", "name"},
+				fld("groups",
+					pageFields,
+					fld("groups",
+						scalars{"id", "displayName"},
+						fld("roles", scalars{"totalCount"}),
+					),
+				).args(arg("cursor", groupCursorVar)),
+			),
+		),
+	)
+}
+
+func composeAllGroupsWithRoleQuery() operation {
+	return query("ListGroupsWithRole",
+		authorizationDomains(
+			pageFields,
+			fld("authenticationDomains",
+				scalars{"id", "name"},
+				fld("groups",
+					pageFields,
+					fld("groups",
+						scalars{"id", "displayName"},
+						fld("roles",
+							pageFields,
+							fld("roles", roleGrantFields),
+						).args(arg("roleId", roleIdsVar)),
+					),
+				).args(arg("cursor", groupCursorVar)),
+			),
+		),
+	)
+}
+
+func composeGroupRoleGrantsQuery() operation {
+	return query("ListGroupRoleGrants",
+		authorizationDomains(
+			fld("authenticationDomains",
+				scalars{"id"},
+				fld("groups",
+					fld("groups",
+						scalars{"id", "displayName"},
+						fld("roles",
+							pageFields,
+							fld("roles", roleGrantFields),
+						).args(arg("roleId", roleIdsVar), arg("cursor", roleCursorVar)),
+					),
+				).args(arg("id", groupIdsVar)),
+			),
+		),
+	)
+}
+
+func composeGroupMembersQuery() operation {
+	return query("ListGroupMembers",
+		userManagementDomains(
+			fld("groups",
+				pageFields,
+				fld("groups",
+					scalars{"id", "displayName"},
+					groupMembersFields.args(arg("cursor", membersCursorVar)),
+				),
+			).args(arg("id", groupIdsVar)),
+		),
+	)
+}
+
+// composeGroupMemberQuery composes query for membership of a single user in the group.
+func composeGroupMemberQuery() operation {
+	return query("GetGroupMember",
+		userManagementDomains(
+			fld("groups",
+				fld("groups",
+					scalars{"id"},
+					fld("users", fld("users", scalars{"id"})).args(arg("id", userIdsVar)),
+				),
+			).args(arg("id", groupIdsVar)),
+		),
+	)
+}
+
+// composeGroupsMembersQuery composes query for members of n groups within a domain, each group under its own alias.
+func composeGroupsMembersQuery(n int) operation {
+	aliases := make([]selector, 0, n)
+	for i := 0; i < n; i++ {
+		groupId := variable{fmt.Sprintf("groupId%d", i), groupIdsVar.typ}
+		membersCursor := variable{fmt.Sprintf("membersCursor%d", i), membersCursorVar.typ}
+
+		aliases = append(aliases,
+			fld("groups",
+				fld("groups",
+					scalars{"id"},
+					groupMembersFields.args(arg("cursor", membersCursor)),
+				),
+			).args(arg("id", groupId)).as(fmt.Sprintf("g%d", i)),
+		)
+	}
+
+	return query("ListGroupsMembers", userManagementDomains(aliases...))
+}
+
+func composeAddGroupMemberMutation() operation {
+	return mutation("AddGroupMember",
+		fld("userManagementAddUsersToGroups",
+			fld("groups", scalars{"displayName", "id"}),
+		).args(arg("addUsersToGroupsOptions", object{
+			arg("groupIds", list{groupIdVar}),
+			arg("userIds", list{userIdVar}),
+		})),
+	)
+}
+
+func composeRemoveGroupMemberMutation() operation {
+	return mutation("RemoveGroupMember",
+		fld("userManagementRemoveUsersFromGroups",
+			fld("groups", scalars{"displayName", "id"}),
+		).args(arg("removeUsersFromGroupsOptions", object{
+			arg("groupIds", list{groupIdVar}),
+			arg("userIds", list{userIdVar}),
+		})),
+	)
+}
+
+func composeCreateGroupMutation() operation {
+	return mutation("CreateGroup",
+		fld("userManagementCreateGroup",
+			fld("group", scalars{"id", "displayName"}),
+		).args(arg("createGroupOptions", object{
+			arg("authenticationDomainId", domainIdVar),
+			arg("displayName", nameVar),
+		})),
+	)
+}
+
+func composeDeleteGroupMutation() operation {
+	return mutation("DeleteGroup",
+		fld("userManagementDeleteGroup",
+			fld("group", scalars{"id"}),
+		).args(arg("groupOptions", object{arg("id", groupIdVar)})),
+	)
 }
 
-func composeUsersQuery() string {
-	return fmt.Sprintf(
-		`query ListUsers($userCursor: String) {
-			%s
-		}`, UsersQ)
+func composeCreateCustomRoleMutation() operation {
+	return mutation("CreateCustomRole",
+		fld("customRoleCreate", scalars{"id"}).args(
+			arg("container", object{arg("id", orgIdVar), arg("type", str("ORGANIZATION"))}),
+			arg("name", nameVar),
+			arg("permissionIds", permissionIdsVar),
+			arg("scope", scopeVar),
+		),
+	)
 }
 
-func composeOrgQuery() string {
-	return fmt.Sprintf(
-		`query GetOrg {
-			%s
-		}`, OrgDetailQ)
+func composeDeleteCustomRoleMutation() operation {
+	return mutation("DeleteCustomRole",
+		fld("customRoleDelete", scalars{"id"}).args(arg("id", customRoleIdVar)),
+	)
 }
 
-func composeRolesQuery() string {
-	return fmt.Sprintf(
-		`query ListRoles($roleCursor: String) {
-			%s
-		}`, RolesQ)
+func composeDeleteAPIKeysMutation() operation {
+	return mutation("DeleteAPIKeys",
+		fld("apiAccessDeleteKeys",
+			fld("deletedKeys", scalars{"id"}),
+			fld("errors", scalars{"message"}),
+		).args(arg("keys", object{
+			arg("userKeyIds", userKeyIdsVar),
+			arg("ingestKeyIds", ingestKeyIdsVar),
+		})),
+	)
 }
 
-func composeDomainsQuery() string {
-	return fmt.Sprintf(
-		`query ListDomains($cursor: String) {
-			%s
-		}`, DomainsQ)
+func composeCreateUserMutation() operation {
+	return mutation("CreateUser",
+		fld("userManagementCreateUser",
+			fld("createdUser", scalars{"id", "email", "name"}, userTypeField),
+		).args(arg("createUserOptions", object{
+			arg("authenticationDomainId", domainIdVar),
+			arg("email", emailVar),
+			arg("name", nameVar),
+			arg("userType", userTierVar),
+		})),
+	)
 }
 
-func composeGroupsQuery() string {
-	return fmt.Sprintf(
-		`query ListGroups($domainId: [ID!], $groupCursor: String) {
-			%s
-		}`, GroupsQ)
+func composeDeleteUserMutation() operation {
+	return mutation("DeleteUser",
+		fld("userManagementDeleteUser",
+			fld("deletedUser", scalars{"id"}),
+		).args(arg("deleteUserOptions", object{arg("id", userIdVar)})),
+	)
 }
 
-func composeAllGroupsWithRoleQuery() string {
-	return fmt.Sprintf(
-		`query ListGroups($domainId: [ID!], $roleId: [ID!], $groupCursor: String) {
-			%s
-		}`, GroupRolesQ)
+func composeUpdateUserTypeMutation() operation {
+	return mutation("UpdateUserType",
+		fld("userManagementUpdateUser",
+			fld("user", scalars{"id"}, userTypeField),
+		).args(arg("updateUserOptions", object{
+			arg("id", userIdVar),
+			arg("userType", newUserTierVar),
+		})),
+	)
 }
 
-func composeGroupMembersQuery() string {
-	return fmt.Sprintf(
-		`query ListGroupMembers($domainId: [ID!], $groupId: [ID!], $membersCursor: String) {
-			%s
-		}`, GroupMembersQ)
+// Access grants of roles to groups, by scope of the role.
+var (
+	groupAccessGrants   = arg("groupAccessGrants", object{arg("groupId", groupIdVar), arg("roleId", roleIdVar)})
+	accountAccessGrants = arg("accountAccessGrants", object{arg("accountId", accountIdVar), arg("roleId", roleIdVar)})
+	orgAccessGrants     = arg("organizationAccessGrants", object{arg("roleId", roleIdVar)})
+)
+
+// grantAccess composes mutation granting access of the role to the group.
+func grantAccess(name string, grants argument) operation {
+	return mutation(name,
+		fld("authorizationManagementGrantAccess", accessRolesFields).args(
+			arg("grantAccessOptions", object{arg("groupId", groupIdVar), grants}),
+		),
+	)
 }
 
-func composeAddGroupMemberMutation() string {
-	return fmt.Sprintf(
-		`mutation AddGroupMember($groupId: ID!, $userId: ID!) {
-			%s
-		}`, addGroupMemberMutation)
+// revokeAccess composes mutation revoking access of the role from the group.
+func revokeAccess(name string, grants argument) operation {
+	return mutation(name,
+		fld("authorizationManagementRevokeAccess", accessRolesFields).args(
+			arg("revokeAccessOptions", object{arg("groupId", groupIdVar), grants}),
+		),
+	)
 }
 
-func composeRemoveGroupMemberMutation() string {
-	return fmt.Sprintf(
-		`mutation RemoveGroupMember($groupId: ID!, $userId: ID!) {
-			%s
-		}`, removeGroupMemberMutation)
+func composeAddGroupRoleMutation() operation {
+	return grantAccess("AddGroupRole", groupAccessGrants)
 }
 
-func composeAddGroupRoleMutation() string {
-	return fmt.Sprintf(
-		`mutation AddGroupRole($groupId: ID!, $roleId: ID!) {
-			%s
-		}`, AddGroupRole)
+func composeAddAccountRoleMutation() operation {
+	return grantAccess("AddAccountRole", accountAccessGrants)
 }
 
-func composeAddAccountRoleMutation() string {
-	return fmt.Sprintf(
-		`mutation AddAccountRole($accountId: Int!, $groupId: ID!, $roleId: ID!) {
-			%s
-		}`, AddAccountRole)
+func composeAddOrgRoleMutation() operation {
+	return grantAccess("AddOrgRole", orgAccessGrants)
 }
 
-func composeAddOrgRoleMutation() string {
-	return fmt.Sprintf(
-		`mutation AddOrgRole($groupId: ID!, $roleId: ID!) {
-			%s
-		}`, AddOrgRole)
+func composeRemoveGroupRoleMutation() operation {
+	return revokeAccess("RemoveGroupRole", groupAccessGrants)
 }
 
-func composeRemoveGroupRoleMutation() string {
-	return fmt.Sprintf(
-		`mutation RemoveGroupRole($groupId: ID!, $roleId: ID!) {
-			%s
-		}`, RemoveGroupRole)
+func composeRemoveAccountRoleMutation() operation {
+	return revokeAccess("RemoveAccountRole", accountAccessGrants)
 }
 
-func composeRemoveAccountRoleMutation() string {
-	return fmt.Sprintf(
-		`mutation RemoveAccountRole($accountId: Int!, $groupId: ID!, $roleId: ID!) {
-			%s
-		}`, RemoveAccountRole)
+func composeRemoveOrgRoleMutation() operation {
+	return revokeAccess("RemoveOrgRole", orgAccessGrants)
 }
 
-func composeRemoveOrgRoleMutation() string {
-	return fmt.Sprintf(
-		`mutation RemoveOrgRole($groupId: ID!, $roleId: ID!) {
-			%s
-		}`, RemoveOrgRole)
+//go:generate go run ../../internal/cmd/nerdgraph-schema -operations testdata/operations -out testdata/nerdgraph.graphql
+
+// operations returns all operations sent by the client, batched queries are composed for two groups.
+func operations() []operation {
+	return []operation{
+		composeAccountsQuery(),
+		composeUsersQueryV2(),
+		composeUserQuery(),
+		composeUsersQuery(),
+		composeOrgQuery(),
+		composeCurrentUserQuery(),
+		composeAPIKeysQuery(),
+		composeNrqlQuery(),
+		composeRolesQuery(),
+		composeRolesPermissionsQuery(2),
+		composeDomainsQuery(),
+		composeGroupsQuery(),
+		composeAllGroupsWithRoleQuery(),
+		composeGroupRoleGrantsQuery(),
+		composeGroupMembersQuery(),
+		composeGroupMemberQuery(),
+		composeGroupsMembersQuery(2),
+		composeAddGroupMemberMutation(),
+		composeRemoveGroupMemberMutation(),
+		composeCreateGroupMutation(),
+		composeDeleteGroupMutation(),
+		composeCreateCustomRoleMutation(),
+		composeDeleteCustomRoleMutation(),
+		composeDeleteAPIKeysMutation(),
+		composeCreateUserMutation(),
+		composeDeleteUserMutation(),
+		composeUpdateUserTypeMutation(),
+		composeAddGroupRoleMutation(),
+		composeAddAccountRoleMutation(),
+		composeAddOrgRoleMutation(),
+		composeRemoveGroupRoleMutation(),
+		composeRemoveAccountRoleMutation(),
+		composeRemoveOrgRoleMutation(),
+	}
 }
 
 // Request body structure for graphql queries and mutations.
@@ -360,9 +544,7 @@ type QueryResponse[T any] struct {
 }
 
 type AccountsResponse = QueryResponse[struct {
-	Accounts []struct {
-		ID int `json:"id"`
-	} `json:"accounts"`
+	Accounts []Account `json:"accounts"`
 }]
 
 type ListBase struct {
@@ -394,6 +576,27 @@ type UsersResponseV2 = QueryResponse[struct {
 	} `json:"organization"`
 }]
 
+type CurrentUserResponse = QueryResponse[struct {
+	User CurrentUser `json:"user"`
+}]
+
+type APIKeysResponse = QueryResponse[struct {
+	APIAccess struct {
+		KeySearch struct {
+			NextCursor string   `json:"nextCursor"`
+			Keys       []APIKey `json:"keys"`
+		} `json:"keySearch"`
+	} `json:"apiAccess"`
+}]
+
+type NrqlResponse = QueryResponse[struct {
+	Account struct {
+		Nrql struct {
+			Results []map[string]interface{} `json:"results"`
+		} `json:"nrql"`
+	} `json:"account"`
+}]
+
 type OrgResponse[T any] QueryResponse[struct {
 	Organization T `json:"organization"`
 }]
@@ -427,6 +630,16 @@ type RolesResponse = OrgResponse[struct {
 	} `json:"authorizationManagement"`
 }]
 
+// RolesPermissionsResponse holds permissions of roles keyed by alias of the role, see composeRolesPermissionsQuery.
+type RolesPermissionsResponse struct {
+	Data struct {
+		CustomerAdministration map[string]struct {
+			NextCursor string       `json:"nextCursor"`
+			Items      []Permission `json:"items"`
+		} `json:"customerAdministration"`
+	} `json:"data"`
+}
+
 type OrgUserManagementResponse[T any] OrgResponse[struct {
 	Management struct {
 		Domains struct {
@@ -451,6 +664,17 @@ type GroupMembersResponse = OrgUserManagementResponse[struct {
 	} `json:"groups"`
 }]
 
+// GroupsMembersResponse holds members of groups keyed by alias of the group, see composeGroupsMembersQuery.
+type GroupsMembersResponse = OrgUserManagementResponse[map[string]struct {
+	Groups []struct {
+		ID    string `json:"id"`
+		Users struct {
+			ListBase
+			Users []BaseResource `json:"users"`
+		} `json:"users"`
+	} `json:"groups"`
+}]
+
 type AddGroupMemberResponse struct {
 	Data struct {
 		MutData struct {
@@ -473,6 +697,73 @@ type RemoveGroupMemberResponse struct {
 	} `json:"data"`
 }
 
+type CreateGroupResponse struct {
+	Data struct {
+		MutData struct {
+			Group Group `json:"group"`
+		} `json:"userManagementCreateGroup"`
+	} `json:"data"`
+}
+
+type DeleteGroupResponse struct {
+	Data struct {
+		MutData struct {
+			Group BaseResource `json:"group"`
+		} `json:"userManagementDeleteGroup"`
+	} `json:"data"`
+}
+
+type CustomRoleResponse struct {
+	Data struct {
+		Create struct {
+			ID int `json:"id"`
+		} `json:"customRoleCreate"`
+		Delete struct {
+			ID int `json:"id"`
+		} `json:"customRoleDelete"`
+	} `json:"data"`
+}
+
+type DeleteAPIKeysResponse struct {
+	Data struct {
+		MutData struct {
+			DeletedKeys []BaseResource `json:"deletedKeys"`
+			Errors      []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+		} `json:"apiAccessDeleteKeys"`
+	} `json:"data"`
+}
+
+type CreateUserResponse struct {
+	Data struct {
+		MutData struct {
+			CreatedUser UserV2 `json:"createdUser"`
+		} `json:"userManagementCreateUser"`
+	} `json:"data"`
+}
+
+type DeleteUserResponse struct {
+	Data struct {
+		MutData struct {
+			DeletedUser struct {
+				ID string `json:"id"`
+			} `json:"deletedUser"`
+		} `json:"userManagementDeleteUser"`
+	} `json:"data"`
+}
+
+type UpdateUserResponse struct {
+	Data struct {
+		MutData struct {
+			User struct {
+				ID   string   `json:"id"`
+				Type UserType `json:"type"`
+			} `json:"user"`
+		} `json:"userManagementUpdateUser"`
+	} `json:"data"`
+}
+
 type GrantRoleResponse struct {
 	Data struct {
 		MutData struct {
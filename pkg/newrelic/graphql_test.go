@@ -0,0 +1,100 @@
+package newrelic
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files of operations")
+
+// TestOperationsGolden pins every operation sent by the client, run with -update to accept changes.
+func TestOperationsGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "operations")
+
+	seen := make(map[string]bool)
+	for _, op := range operations() {
+		if seen[op.Name()] {
+			t.Errorf("duplicate operation name %s", op.Name())
+		}
+		seen[op.Name()] = true
+
+		path := filepath.Join(dir, op.Name()+".graphql")
+		got := op.String()
+
+		if *update {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(path, []byte(got), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			continue
+		}
+
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("missing golden file of %s, run tests with -update: %v", op.Name(), err)
+			continue
+		}
+
+		if got != string(want) {
+			t.Errorf("operation %s differs from %s:\n%s", op.Name(), path, got)
+		}
+	}
+
+	// golden files are the operations the schema is generated for, files of removed operations have to go
+	paths, err := filepath.Glob(filepath.Join(dir, "*.graphql"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range paths {
+		name := strings.TrimSuffix(filepath.Base(path), ".graphql")
+		if seen[name] {
+			continue
+		}
+
+		if *update {
+			if err := os.Remove(path); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		t.Errorf("golden file %s has no operation, run tests with -update", path)
+	}
+}
+
+func TestOperationValidate(t *testing.T) {
+	op := composeGroupMembersQuery()
+
+	if err := op.validate(map[string]interface{}{"domainId": "d1", "groupId": "g1"}); err != nil {
+		t.Errorf("expected variables to be valid, got %v", err)
+	}
+
+	if err := op.validate(map[string]interface{}{"domainId": "d1", "userCursor": "c"}); err == nil {
+		t.Errorf("expected variable not used by the operation to be rejected")
+	}
+
+	if err := composeDeleteUserMutation().validate(nil); err == nil {
+		t.Errorf("expected missing required variable to be rejected")
+	}
+}
+
+func TestOperationVariableConflict(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected variable declared with two types to panic")
+		}
+	}()
+
+	_ = query("Conflict",
+		fld("a", scalars{"id"}).args(arg("id", groupIdVar)),
+		fld("b", scalars{"id"}).args(arg("id", groupIdsVar)),
+	).String()
+}
@@ -1,5 +1,10 @@
 package newrelic
 
+import (
+	"strings"
+	"time"
+)
+
 type BaseResource struct {
 	ID string `json:"id"`
 }
@@ -8,13 +13,110 @@ type User struct {
 	ID    string `json:"userId"`
 	Email string `json:"email"`
 	Name  string `json:"name"`
+	// DomainID is the authentication domain of the user, empty for users from legacy user search.
+	DomainID string `json:"-"`
+	// Type is the user type, not available for users from legacy user search.
+	Type UserType `json:"-"`
+	// EmailVerificationState is the state of email verification, not available for users from legacy user search.
+	EmailVerificationState string `json:"-"`
+	// LastActive is the time of the last activity of the user, nil if the user has never been active
+	// or comes from legacy user search.
+	LastActive *time.Time `json:"-"`
+	TimeZone   string     `json:"-"`
+}
+
+// IsLegacy reports whether the user comes from legacy user search, which lacks domain, type and state of the user.
+func (u User) IsLegacy() bool {
+	return u.DomainID == ""
+}
+
+// IsPendingVerification reports whether the user has not verified the email address yet.
+func (u User) IsPendingVerification() bool {
+	return strings.EqualFold(u.EmailVerificationState, EmailVerificationPending)
+}
+
+// EmailVerificationPending is the state of users invited to the organization who have not verified their email.
+const EmailVerificationPending = "Pending"
+
+// CurrentUser is the user who owns the API key used by the client.
+type CurrentUser struct {
+	ID    int    `json:"id"`
+	Email string `json:"email"`
+	Name  string `json:"name"`
 }
 
 type UserV2 struct {
-	ID                     string `json:"id"`
-	Email                  string `json:"email"`
-	Name                   string `json:"name"`
-	EmailVerificationState string `json:"emailVerificationState"`
+	ID                     string     `json:"id"`
+	Email                  string     `json:"email"`
+	Name                   string     `json:"name"`
+	EmailVerificationState string     `json:"emailVerificationState"`
+	Type                   UserType   `json:"type"`
+	LastActive             *time.Time `json:"lastActive"`
+	TimeZone               string     `json:"timeZone"`
+}
+
+// Tiers of user types, used to change user type (see more here: https://docs.newrelic.com/docs/accounts/accounts-billing/new-relic-one-user-management/user-type)
+const (
+	UserTierBasic = "BASIC_USER_TIER"
+	UserTierCore  = "CORE_USER_TIER"
+	UserTierFull  = "FULL_USER_TIER"
+)
+
+type UserType struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+}
+
+// Tier returns the tier of the user type, empty if the type is not known.
+func (t UserType) Tier() string {
+	switch strings.ToLower(t.DisplayName) {
+	case "basic":
+		return UserTierBasic
+	case "core":
+		return UserTierCore
+	case "full platform", "full":
+		return UserTierFull
+	}
+
+	switch t.ID {
+	case "0":
+		return UserTierBasic
+	case "1":
+		return UserTierFull
+	case "2":
+		return UserTierCore
+	}
+
+	return ""
+}
+
+type Account struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Types of API keys (see more here: https://docs.newrelic.com/docs/apis/intro-apis/new-relic-api-keys)
+const (
+	APIKeyTypeUser   = "USER"
+	APIKeyTypeIngest = "INGEST"
+)
+
+// APIKey is a user key or an ingest (license or browser) key.
+type APIKey struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// CreatedAt is the creation time of the key in epoch seconds.
+	CreatedAt int64 `json:"createdAt"`
+	AccountID int   `json:"accountId"`
+	// IngestType is either LICENSE or BROWSER, empty for user keys.
+	IngestType string `json:"ingestType"`
+	// User is the owner of the key, nil for ingest keys.
+	User *struct {
+		ID    int    `json:"id"`
+		Email string `json:"email"`
+		Name  string `json:"name"`
+	} `json:"user"`
 }
 
 type Org struct {
@@ -24,26 +126,69 @@ type Org struct {
 
 // authentication domain (see more here: https://docs.newrelic.com/docs/accounts/accounts-billing/new-relic-one-user-management/authentication-domains-saml-sso-scim-more)
 type Domain struct {
-	ID         string  `json:"id"`
-	Name       string  `json:"name"`
-	NextCursor string  `json:"nextCursor"`
-	Total      int     `json:"totalCount"`
-	Groups     []Group `json:"groups"`
+	ID                 string  `json:"id"`
+	Name               string  `json:"name"`
+	NextCursor         string  `json:"nextCursor"`
+	Total              int     `json:"totalCount"`
+	UsersTotal         int     `json:"usersTotalCount"`
+	ProvisioningType   string  `json:"provisioningType"`
+	AuthenticationType string  `json:"authenticationType"`
+	Groups             []Group `json:"groups"`
 }
 
 type Group struct {
 	BaseResource
 	Name  string `json:"displayName"`
 	Roles struct {
-		NextCursor string `json:"nextCursor"`
-		TotalCount int    `json:"totalCount"`
-		Roles      []Role `json:"roles"`
+		NextCursor string      `json:"nextCursor"`
+		TotalCount int         `json:"totalCount"`
+		Roles      []RoleGrant `json:"roles"`
 	} `json:"roles"`
 }
 
+// GroupMembers is a page of members of the group. Err is set when the group was not found,
+// so that a missing group doesn't fail other groups fetched in the same request.
+type GroupMembers struct {
+	GroupID    string
+	Members    []string
+	NextCursor string
+	Err        error
+}
+
 type Role struct {
 	BaseResource
 	DisplayName string `json:"displayName"`
 	Name        string `json:"name"`
 	Scope       string `json:"scope"`
+	// Type is either standard role managed by NewRelic or custom role managed by the organization.
+	Type string `json:"type"`
+}
+
+// RoleTypeCustom is the type of roles created by the organization.
+const RoleTypeCustom = "custom"
+
+// Permission is a capability granted by a role.
+type Permission struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Feature  string `json:"feature"`
+	Category string `json:"category"`
+}
+
+// RolePermissions is page of permissions granted by the role.
+type RolePermissions struct {
+	RoleID      string
+	Permissions []Permission
+	NextCursor  string
+}
+
+// RoleGrant is an access grant of a role to a group, targeting an account, the organization or the group itself.
+type RoleGrant struct {
+	ID             string `json:"id"`
+	RoleID         int    `json:"roleId"`
+	Name           string `json:"name"`
+	DisplayName    string `json:"displayName"`
+	AccountID      int    `json:"accountId"`
+	OrganizationID string `json:"organizationId"`
+	Type           string `json:"type"`
 }
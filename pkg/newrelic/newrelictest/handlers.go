@@ -0,0 +1,876 @@
+package newrelictest
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+)
+
+type obj = map[string]interface{}
+
+type handler func(s *Server, v vars) (interface{}, error)
+
+// handlers of operations sent by the client, keyed by operation name.
+var handlers = map[string]handler{
+	"ListAccounts":         (*Server).listAccounts,
+	"GetOrg":               (*Server).getOrg,
+	"GetCurrentUser":       (*Server).getCurrentUser,
+	"ListRoles":            (*Server).listRoles,
+	"ListRolesPermissions": (*Server).listRolesPermissions,
+	"ListDomains":          (*Server).listDomains,
+	"ListUsers":            (*Server).listUsers,
+	"GetUser":              (*Server).getUser,
+	"SearchUsers":          (*Server).searchUsers,
+	"ListGroups":           (*Server).listGroups,
+	"ListGroupsWithRole":   (*Server).listGroupsWithRole,
+	"ListGroupRoleGrants":  (*Server).listGroupRoleGrants,
+	"ListGroupMembers":     (*Server).listGroupMembers,
+	"GetGroupMember":       (*Server).getGroupMember,
+	"ListGroupsMembers":    (*Server).listGroupsMembers,
+	"ListAPIKeys":          (*Server).listAPIKeys,
+	"RunNrql":              (*Server).runNrql,
+
+	"AddGroupMember":    (*Server).addGroupMember,
+	"RemoveGroupMember": (*Server).removeGroupMember,
+	"CreateUser":        (*Server).createUser,
+	"DeleteUser":        (*Server).deleteUser,
+	"UpdateUserType":    (*Server).updateUserType,
+	"CreateGroup":       (*Server).createGroup,
+	"DeleteGroup":       (*Server).deleteGroup,
+	"AddGroupRole":      (*Server).addRole,
+	"AddAccountRole":    (*Server).addRole,
+	"AddOrgRole":        (*Server).addRole,
+	"RemoveGroupRole":   (*Server).removeRole,
+	"RemoveAccountRole": (*Server).removeRole,
+	"RemoveOrgRole":     (*Server).removeRole,
+	"CreateCustomRole":  (*Server).createCustomRole,
+	"DeleteCustomRole":  (*Server).deleteCustomRole,
+	"DeleteAPIKeys":     (*Server).deleteAPIKeys,
+}
+
+func actor(v interface{}) obj {
+	return obj{"actor": v}
+}
+
+func organization(v interface{}) obj {
+	return actor(obj{"organization": v})
+}
+
+func (s *Server) listAccounts(_ vars) (interface{}, error) {
+	accounts := []obj{}
+	for _, a := range s.accounts {
+		accounts = append(accounts, obj{"id": a.ID, "name": a.Name})
+	}
+
+	return actor(obj{"accounts": accounts}), nil
+}
+
+func (s *Server) getOrg(_ vars) (interface{}, error) {
+	return organization(obj{"id": s.orgID, "name": s.orgName}), nil
+}
+
+func (s *Server) getCurrentUser(_ vars) (interface{}, error) {
+	u := s.findUser(s.currentUserID)
+	if u == nil {
+		return nil, errorf("NOT_FOUND", "current user not set")
+	}
+
+	id, _ := strconv.Atoi(u.ID)
+
+	return actor(obj{"user": obj{"id": id, "email": u.Email, "name": u.Name}}), nil
+}
+
+func (s *Server) listRoles(v vars) (interface{}, error) {
+	start, end, next, err := s.paginate(len(s.roles), v.str("roleCursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []obj{}
+	for _, r := range s.roles[start:end] {
+		roles = append(roles, obj{
+			"id":          r.ID,
+			"name":        r.Name,
+			"displayName": r.DisplayName,
+			"scope":       r.Scope,
+			"type":        r.Type,
+		})
+	}
+
+	return organization(obj{
+		"authorizationManagement": obj{
+			"roles": obj{"nextCursor": next, "totalCount": len(s.roles), "roles": roles},
+		},
+	}), nil
+}
+
+func (s *Server) listRolesPermissions(v vars) (interface{}, error) {
+	fields := obj{}
+	for i := 0; ; i++ {
+		suffix := strconv.Itoa(i)
+		if _, ok := v["roleId"+suffix]; !ok {
+			break
+		}
+
+		// filter matching no role yields no permissions
+		var permissionIds []int
+		if r := s.findRole(v.str("roleId" + suffix)); r != nil {
+			permissionIds = r.PermissionIDs
+		}
+
+		start, end, next, err := s.paginate(len(permissionIds), v.str("permissionCursor"+suffix))
+		if err != nil {
+			return nil, err
+		}
+
+		items := []obj{}
+		for _, id := range permissionIds[start:end] {
+			for _, p := range s.permissions {
+				if p.ID == id {
+					items = append(items, obj{
+						"id":       strconv.Itoa(p.ID),
+						"name":     p.Name,
+						"feature":  p.Feature,
+						"category": p.Category,
+					})
+				}
+			}
+		}
+
+		fields["r"+suffix] = obj{"nextCursor": next, "items": items}
+	}
+
+	return obj{"customerAdministration": fields}, nil
+}
+
+func (s *Server) listDomains(v vars) (interface{}, error) {
+	start, end, next, err := s.paginate(len(s.domains), v.str("cursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	domains := []obj{}
+	for _, d := range s.domains[start:end] {
+		domains = append(domains, obj{
+			"id":                 d.ID,
+			"name":               d.Name,
+			"provisioningType":   d.ProvisioningType,
+			"authenticationType": d.AuthenticationType,
+			"users":              obj{"totalCount": len(s.domainUsers(d.ID))},
+			"groups":             obj{"totalCount": len(s.domainGroups(d.ID))},
+		})
+	}
+
+	return organization(obj{
+		"userManagement": obj{
+			"authenticationDomains": obj{
+				"nextCursor":            next,
+				"totalCount":            len(s.domains),
+				"authenticationDomains": domains,
+			},
+		},
+	}), nil
+}
+
+func (s *Server) domainUsers(domainId string) []*User {
+	var rv []*User
+	for _, u := range s.users {
+		if u.DomainID == domainId {
+			rv = append(rv, u)
+		}
+	}
+
+	return rv
+}
+
+func (s *Server) domainGroups(domainId string) []*Group {
+	var rv []*Group
+	for _, g := range s.groups {
+		if g.DomainID == domainId {
+			rv = append(rv, g)
+		}
+	}
+
+	return rv
+}
+
+// userType returns user type of the tier, in the same form as NerdGraph returns it.
+func userType(tier string) obj {
+	switch tier {
+	case newrelic.UserTierFull:
+		return obj{"displayName": "Full platform", "id": "1"}
+	case newrelic.UserTierCore:
+		return obj{"displayName": "Core", "id": "2"}
+	default:
+		return obj{"displayName": "Basic", "id": "0"}
+	}
+}
+
+func userV2(u *User) obj {
+	rv := obj{
+		"id":                     u.ID,
+		"email":                  u.Email,
+		"name":                   u.Name,
+		"emailVerificationState": u.EmailVerificationState,
+		"timeZone":               u.TimeZone,
+		"lastActive":             nil,
+		"type":                   userType(u.Tier),
+	}
+
+	if u.LastActive != nil {
+		rv["lastActive"] = u.LastActive.UTC().Format(time.RFC3339)
+	}
+
+	return rv
+}
+
+func (s *Server) listUsers(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return organization(obj{
+			"userManagement": obj{"authenticationDomains": obj{"authenticationDomains": []obj{}}},
+		}), nil
+	}
+
+	users := s.domainUsers(d.ID)
+	start, end, next, err := s.paginate(len(users), v.str("userCursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	page := []obj{}
+	for _, u := range users[start:end] {
+		page = append(page, userV2(u))
+	}
+
+	return organization(obj{
+		"userManagement": obj{
+			"authenticationDomains": obj{
+				"authenticationDomains": []obj{
+					{"users": obj{"nextCursor": next, "totalCount": len(users), "users": page}},
+				},
+			},
+		},
+	}), nil
+}
+
+func (s *Server) getUser(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return organization(obj{
+			"userManagement": obj{"authenticationDomains": obj{"authenticationDomains": []obj{}}},
+		}), nil
+	}
+
+	users := []obj{}
+	for _, u := range s.domainUsers(d.ID) {
+		if contains(v.list("userId"), u.ID) {
+			users = append(users, userV2(u))
+		}
+	}
+
+	return userManagementDomain(obj{"users": obj{"users": users}}), nil
+}
+
+func (s *Server) searchUsers(v vars) (interface{}, error) {
+	start, end, next, err := s.paginate(len(s.users), v.str("userCursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	users := []obj{}
+	for _, u := range s.users[start:end] {
+		users = append(users, obj{"userId": u.ID, "email": u.Email, "name": u.Name})
+	}
+
+	return actor(obj{
+		"users": obj{
+			"userSearch": obj{"nextCursor": next, "totalCount": len(s.users), "users": users},
+		},
+	}), nil
+}
+
+// authorizationDomain wraps the domain into authorizationManagement response of groups.
+func authorizationDomain(d *Domain, groups obj) interface{} {
+	return organization(obj{
+		"authorizationManagement": obj{
+			"authenticationDomains": obj{
+				"authenticationDomains": []obj{
+					{"id": d.ID, "name": d.Name, "groups": groups},
+				},
+			},
+		},
+	})
+}
+
+func (s *Server) listGroups(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	groups := s.domainGroups(d.ID)
+	start, end, next, err := s.paginate(len(groups), v.str("groupCursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	page := []obj{}
+	for _, g := range groups[start:end] {
+		page = append(page, obj{
+			"id":          g.ID,
+			"displayName": g.Name,
+			"roles":       obj{"totalCount": len(s.groupGrants(g.ID, ""))},
+		})
+	}
+
+	return authorizationDomain(d, obj{"nextCursor": next, "totalCount": len(groups), "groups": page}), nil
+}
+
+// groupGrants returns grants of the role to the group, grants of all roles if role is empty.
+func (s *Server) groupGrants(groupId, roleId string) []*RoleGrant {
+	var rv []*RoleGrant
+	for _, g := range s.grants {
+		if g.GroupID == groupId && (roleId == "" || g.RoleID == roleId) {
+			rv = append(rv, g)
+		}
+	}
+
+	return rv
+}
+
+func (s *Server) roleGrant(g *RoleGrant) obj {
+	roleId, _ := strconv.Atoi(g.RoleID)
+	rv := obj{
+		"id":             g.ID,
+		"roleId":         roleId,
+		"accountId":      g.AccountID,
+		"organizationId": s.orgID,
+	}
+
+	if r := s.findRole(g.RoleID); r != nil {
+		rv["name"] = r.Name
+		rv["displayName"] = r.DisplayName
+		rv["type"] = r.Scope
+	}
+
+	return rv
+}
+
+// groupRoles returns page of grants to the group in the form of roles field of the group.
+func (s *Server) groupRoles(groupId, roleId, cursor string) (obj, error) {
+	grants := s.groupGrants(groupId, roleId)
+	start, end, next, err := s.paginate(len(grants), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := []obj{}
+	for _, g := range grants[start:end] {
+		roles = append(roles, s.roleGrant(g))
+	}
+
+	return obj{"nextCursor": next, "totalCount": len(grants), "roles": roles}, nil
+}
+
+func (s *Server) listGroupsWithRole(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	groups := s.domainGroups(d.ID)
+	start, end, next, err := s.paginate(len(groups), v.str("groupCursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	page := []obj{}
+	for _, g := range groups[start:end] {
+		roles, err := s.groupRoles(g.ID, v.str("roleId"), "")
+		if err != nil {
+			return nil, err
+		}
+
+		page = append(page, obj{"id": g.ID, "displayName": g.Name, "roles": roles})
+	}
+
+	return authorizationDomain(d, obj{"nextCursor": next, "totalCount": len(groups), "groups": page}), nil
+}
+
+func (s *Server) listGroupRoleGrants(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	page := []obj{}
+	if g := s.findGroup(v.str("groupId")); g != nil && g.DomainID == d.ID {
+		roles, err := s.groupRoles(g.ID, v.str("roleId"), v.str("roleCursor"))
+		if err != nil {
+			return nil, err
+		}
+
+		page = append(page, obj{"id": g.ID, "displayName": g.Name, "roles": roles})
+	}
+
+	return authorizationDomain(d, obj{"groups": page}), nil
+}
+
+// groupUsers returns page of members of the group in the form of users field of the group.
+func (s *Server) groupUsers(g *Group, cursor string) (obj, error) {
+	start, end, next, err := s.paginate(len(g.Members), cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	users := []obj{}
+	for _, id := range g.Members[start:end] {
+		users = append(users, obj{"id": id})
+	}
+
+	return obj{"nextCursor": next, "totalCount": len(g.Members), "users": users}, nil
+}
+
+// userManagementDomain wraps the domain fields into userManagement response.
+func userManagementDomain(fields obj) interface{} {
+	return organization(obj{
+		"userManagement": obj{
+			"authenticationDomains": obj{
+				"authenticationDomains": []obj{fields},
+			},
+		},
+	})
+}
+
+func (s *Server) listGroupMembers(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	page := []obj{}
+	if g := s.findGroup(v.str("groupId")); g != nil && g.DomainID == d.ID {
+		users, err := s.groupUsers(g, v.str("membersCursor"))
+		if err != nil {
+			return nil, err
+		}
+
+		page = append(page, obj{"id": g.ID, "displayName": g.Name, "users": users})
+	}
+
+	return userManagementDomain(obj{"groups": obj{"groups": page}}), nil
+}
+
+func (s *Server) getGroupMember(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	page := []obj{}
+	if g := s.findGroup(v.str("groupId")); g != nil && g.DomainID == d.ID {
+		users := []obj{}
+		for _, id := range v.list("userId") {
+			if contains(g.Members, id) {
+				users = append(users, obj{"id": id})
+			}
+		}
+
+		page = append(page, obj{"id": g.ID, "users": obj{"users": users}})
+	}
+
+	return userManagementDomain(obj{"groups": obj{"groups": page}}), nil
+}
+
+func (s *Server) listGroupsMembers(v vars) (interface{}, error) {
+	d := s.findDomain(v.str("domainId"))
+	if d == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	fields := obj{}
+	for i := 0; ; i++ {
+		suffix := strconv.Itoa(i)
+		if _, ok := v["groupId"+suffix]; !ok {
+			break
+		}
+
+		page := []obj{}
+		if g := s.findGroup(v.str("groupId" + suffix)); g != nil && g.DomainID == d.ID {
+			users, err := s.groupUsers(g, v.str("membersCursor"+suffix))
+			if err != nil {
+				return nil, err
+			}
+
+			page = append(page, obj{"id": g.ID, "users": users})
+		}
+
+		fields["g"+suffix] = obj{"groups": page}
+	}
+
+	return userManagementDomain(fields), nil
+}
+
+func (s *Server) listAPIKeys(v vars) (interface{}, error) {
+	start, end, next, err := s.paginate(len(s.keys), v.str("keyCursor"))
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []obj{}
+	for _, k := range s.keys[start:end] {
+		key := obj{
+			"id":        k.ID,
+			"name":      k.Name,
+			"type":      k.Type,
+			"createdAt": k.CreatedAt.Unix(),
+			"accountId": k.AccountID,
+		}
+
+		if k.IngestType != "" {
+			key["ingestType"] = k.IngestType
+		}
+
+		if u := s.findUser(k.UserID); u != nil {
+			id, _ := strconv.Atoi(u.ID)
+			key["user"] = obj{"id": id, "email": u.Email, "name": u.Name}
+		}
+
+		keys = append(keys, key)
+	}
+
+	return actor(obj{
+		"apiAccess": obj{
+			"keySearch": obj{"nextCursor": next, "count": len(s.keys), "keys": keys},
+		},
+	}), nil
+}
+
+var (
+	nrqlSinceRe = regexp.MustCompile(`timestamp >= (\d+)`)
+	nrqlLimitRe = regexp.MustCompile(`LIMIT (\d+)`)
+)
+
+// maxNrqlLimit is the highest LIMIT accepted by NRQL.
+const maxNrqlLimit = 5000
+
+// runNrql answers NRQL queries for NrAuditEvent sent by the client, other queries return no results.
+func (s *Server) runNrql(v vars) (interface{}, error) {
+	nrql := v.str("nrql")
+
+	var since float64
+	if m := nrqlSinceRe.FindStringSubmatch(nrql); m != nil {
+		since, _ = strconv.ParseFloat(m[1], 64)
+	}
+
+	limit := -1
+	if m := nrqlLimitRe.FindStringSubmatch(nrql); m != nil {
+		limit, _ = strconv.Atoi(m[1])
+	}
+
+	if limit > maxNrqlLimit {
+		return nil, errorf("BAD_USER_INPUT", "NRQL LIMIT %d exceeds maximum of %d", limit, maxNrqlLimit)
+	}
+
+	results := []obj{}
+	if strings.Contains(nrql, "NrAuditEvent") {
+		for _, e := range s.events[v.num("accountId")] {
+			if ts, _ := e["timestamp"].(float64); ts >= since {
+				results = append(results, e)
+			}
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		ti, _ := results[i]["timestamp"].(float64)
+		tj, _ := results[j]["timestamp"].(float64)
+		return ti < tj
+	})
+
+	if limit >= 0 && len(results) > limit {
+		results = results[:limit]
+	}
+
+	return actor(obj{"account": obj{"nrql": obj{"results": results}}}), nil
+}
+
+func (s *Server) addGroupMember(v vars) (interface{}, error) {
+	g, u := s.findGroup(v.str("groupId")), s.findUser(v.str("userId"))
+	if g == nil || u == nil {
+		return nil, errorf("NOT_FOUND", "group %s or user %s not found", v.str("groupId"), v.str("userId"))
+	}
+
+	if !contains(g.Members, u.ID) {
+		g.Members = append(g.Members, u.ID)
+	}
+
+	return obj{
+		"userManagementAddUsersToGroups": obj{"groups": []obj{{"id": g.ID, "displayName": g.Name}}},
+	}, nil
+}
+
+func (s *Server) removeGroupMember(v vars) (interface{}, error) {
+	g := s.findGroup(v.str("groupId"))
+	if g == nil {
+		return nil, errorf("NOT_FOUND", "group %s not found", v.str("groupId"))
+	}
+
+	g.Members = remove(g.Members, v.str("userId"))
+
+	return obj{
+		"userManagementRemoveUsersFromGroups": obj{"groups": []obj{{"id": g.ID, "displayName": g.Name}}},
+	}, nil
+}
+
+func (s *Server) createUser(v vars) (interface{}, error) {
+	if s.findDomain(v.str("domainId")) == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	for _, u := range s.users {
+		if strings.EqualFold(u.Email, v.str("email")) {
+			return nil, errorf("BAD_USER_INPUT", "user %s already exists", v.str("email"))
+		}
+	}
+
+	u := &User{
+		ID:                     s.newID(),
+		Email:                  v.str("email"),
+		Name:                   v.str("name"),
+		DomainID:               v.str("domainId"),
+		Tier:                   v.str("userType"),
+		EmailVerificationState: newrelic.EmailVerificationPending,
+	}
+	s.users = append(s.users, u)
+
+	return obj{
+		"userManagementCreateUser": obj{
+			"createdUser": obj{"id": u.ID, "email": u.Email, "name": u.Name, "type": userType(u.Tier)},
+		},
+	}, nil
+}
+
+func (s *Server) deleteUser(v vars) (interface{}, error) {
+	id := v.str("userId")
+	if s.findUser(id) == nil {
+		return nil, errorf("NOT_FOUND", "user %s not found", id)
+	}
+
+	var users []*User
+	for _, u := range s.users {
+		if u.ID != id {
+			users = append(users, u)
+		}
+	}
+	s.users = users
+
+	for _, g := range s.groups {
+		g.Members = remove(g.Members, id)
+	}
+
+	return obj{"userManagementDeleteUser": obj{"deletedUser": obj{"id": id}}}, nil
+}
+
+func (s *Server) updateUserType(v vars) (interface{}, error) {
+	u := s.findUser(v.str("userId"))
+	if u == nil {
+		return nil, errorf("NOT_FOUND", "user %s not found", v.str("userId"))
+	}
+
+	u.Tier = v.str("userType")
+
+	return obj{"userManagementUpdateUser": obj{"user": obj{"id": u.ID, "type": userType(u.Tier)}}}, nil
+}
+
+func (s *Server) createGroup(v vars) (interface{}, error) {
+	if s.findDomain(v.str("domainId")) == nil {
+		return nil, errorf("NOT_FOUND", "domain %s not found", v.str("domainId"))
+	}
+
+	g := &Group{ID: s.newID(), Name: v.str("name"), DomainID: v.str("domainId")}
+	s.groups = append(s.groups, g)
+
+	return obj{"userManagementCreateGroup": obj{"group": obj{"id": g.ID, "displayName": g.Name}}}, nil
+}
+
+func (s *Server) deleteGroup(v vars) (interface{}, error) {
+	id := v.str("groupId")
+	if s.findGroup(id) == nil {
+		return nil, errorf("NOT_FOUND", "group %s not found", id)
+	}
+
+	var groups []*Group
+	for _, g := range s.groups {
+		if g.ID != id {
+			groups = append(groups, g)
+		}
+	}
+	s.groups = groups
+
+	var grants []*RoleGrant
+	for _, g := range s.grants {
+		if g.GroupID != id {
+			grants = append(grants, g)
+		}
+	}
+	s.grants = grants
+
+	return obj{"userManagementDeleteGroup": obj{"group": obj{"id": id}}}, nil
+}
+
+func (s *Server) addRole(v vars) (interface{}, error) {
+	g, r := s.findGroup(v.str("groupId")), s.findRole(v.str("roleId"))
+	if g == nil || r == nil {
+		return nil, errorf("NOT_FOUND", "group %s or role %s not found", v.str("groupId"), v.str("roleId"))
+	}
+
+	found := false
+	for _, grant := range s.groupGrants(g.ID, r.ID) {
+		if grant.AccountID == v.num("accountId") {
+			found = true
+		}
+	}
+
+	if !found {
+		s.grants = append(s.grants, &RoleGrant{ID: s.newID(), RoleID: r.ID, GroupID: g.ID, AccountID: v.num("accountId")})
+	}
+
+	return obj{"authorizationManagementGrantAccess": s.accessRoles(g.ID)}, nil
+}
+
+func (s *Server) removeRole(v vars) (interface{}, error) {
+	g := s.findGroup(v.str("groupId"))
+	if g == nil {
+		return nil, errorf("NOT_FOUND", "group %s not found", v.str("groupId"))
+	}
+
+	var grants []*RoleGrant
+	for _, grant := range s.grants {
+		if grant.GroupID == g.ID && grant.RoleID == v.str("roleId") && grant.AccountID == v.num("accountId") {
+			continue
+		}
+
+		grants = append(grants, grant)
+	}
+	s.grants = grants
+
+	return obj{"authorizationManagementRevokeAccess": s.accessRoles(g.ID)}, nil
+}
+
+// accessRoles returns roles granted to the group in the form of grant and revoke access mutations.
+func (s *Server) accessRoles(groupId string) obj {
+	roles := []obj{}
+	for _, g := range s.groupGrants(groupId, "") {
+		roleId, _ := strconv.Atoi(g.RoleID)
+		role := obj{"roleId": roleId}
+		if r := s.findRole(g.RoleID); r != nil {
+			role["displayName"] = r.DisplayName
+		}
+
+		roles = append(roles, role)
+	}
+
+	return obj{"roles": roles}
+}
+
+func (s *Server) permissionIds(v vars) []int {
+	var rv []int
+	for _, id := range v.list("permissionIds") {
+		n, _ := strconv.Atoi(id)
+		rv = append(rv, n)
+	}
+
+	return rv
+}
+
+func (s *Server) createCustomRole(v vars) (interface{}, error) {
+	if v.str("orgId") != s.orgID {
+		return nil, errorf("NOT_FOUND", "organization %s not found", v.str("orgId"))
+	}
+
+	r := &Role{
+		ID:            s.newID(),
+		Name:          v.str("name"),
+		DisplayName:   v.str("name"),
+		Scope:         v.str("scope"),
+		Type:          newrelic.RoleTypeCustom,
+		PermissionIDs: s.permissionIds(v),
+	}
+	s.roles = append(s.roles, r)
+
+	id, _ := strconv.Atoi(r.ID)
+
+	return obj{"customRoleCreate": obj{"id": id}}, nil
+}
+
+func (s *Server) deleteCustomRole(v vars) (interface{}, error) {
+	r := s.findRole(v.str("roleId"))
+	if r == nil || r.Type != newrelic.RoleTypeCustom {
+		return nil, errorf("NOT_FOUND", "custom role %s not found", v.str("roleId"))
+	}
+
+	var roles []*Role
+	for _, role := range s.roles {
+		if role.ID != r.ID {
+			roles = append(roles, role)
+		}
+	}
+	s.roles = roles
+
+	return obj{"customRoleDelete": obj{"id": v.num("roleId")}}, nil
+}
+
+func (s *Server) deleteAPIKeys(v vars) (interface{}, error) {
+	deleted := []obj{}
+	errors := []obj{}
+
+	ids := append(v.list("userKeyIds"), v.list("ingestKeyIds")...)
+	for _, id := range ids {
+		found := false
+
+		var keys []*APIKey
+		for _, k := range s.keys {
+			if k.ID == id {
+				found = true
+				continue
+			}
+
+			keys = append(keys, k)
+		}
+		s.keys = keys
+
+		if found {
+			deleted = append(deleted, obj{"id": id})
+		} else {
+			errors = append(errors, obj{"message": "key " + id + " not found"})
+		}
+	}
+
+	return obj{"apiAccessDeleteKeys": obj{"deletedKeys": deleted, "errors": errors}}, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func remove(values []string, value string) []string {
+	var rv []string
+	for _, v := range values {
+		if v != value {
+			rv = append(rv, v)
+		}
+	}
+
+	return rv
+}
@@ -0,0 +1,527 @@
+// Package newrelictest provides an in-process fake of NerdGraph for tests of the NewRelic client and connector.
+//
+// The fake keeps a mutable in-memory model of a single organization and understands queries and mutations
+// sent by the client, dispatched by their operation name. Lists are paginated by PageSize, so tests
+// exercise pagination with small data sets.
+package newrelictest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/conductorone/baton-newrelic/pkg/newrelic"
+)
+
+const defaultPageSize = 2
+
+// Account is an account of the organization.
+type Account struct {
+	ID   int
+	Name string
+}
+
+// Domain is an authentication domain of the organization.
+type Domain struct {
+	ID                 string
+	Name               string
+	ProvisioningType   string
+	AuthenticationType string
+}
+
+// User is a user of an authentication domain.
+type User struct {
+	ID       string
+	Email    string
+	Name     string
+	DomainID string
+	// Tier is one of newrelic.UserTier* constants, basic tier is used if empty.
+	Tier                   string
+	EmailVerificationState string
+	LastActive             *time.Time
+	TimeZone               string
+}
+
+// Group is a group of an authentication domain.
+type Group struct {
+	ID       string
+	Name     string
+	DomainID string
+	Members  []string
+}
+
+// Role is a standard or custom role of the organization.
+type Role struct {
+	ID          string
+	Name        string
+	DisplayName string
+	Scope       string
+	Type        string
+	// PermissionIDs are ids of permissions added by AddPermission.
+	PermissionIDs []int
+}
+
+// Permission is a capability granted by roles.
+type Permission struct {
+	ID       int
+	Name     string
+	Feature  string
+	Category string
+}
+
+// RoleGrant is an access grant of a role to a group, AccountID is set for account scoped roles.
+type RoleGrant struct {
+	ID        string
+	RoleID    string
+	GroupID   string
+	AccountID int
+}
+
+// APIKey is a user or an ingest key, UserID is set for user keys.
+type APIKey struct {
+	ID         string
+	Name       string
+	Type       string
+	AccountID  int
+	UserID     string
+	IngestType string
+	CreatedAt  time.Time
+}
+
+// Server is a fake NerdGraph server.
+type Server struct {
+	*httptest.Server
+
+	// PageSize is the number of items returned in a single page of any list.
+	PageSize int
+
+	mu            sync.Mutex
+	orgID         string
+	orgName       string
+	currentUserID string
+	accounts      []Account
+	domains       []*Domain
+	users         []*User
+	groups        []*Group
+	roles         []*Role
+	permissions   []Permission
+	grants        []*RoleGrant
+	keys          []*APIKey
+	events        map[int][]map[string]interface{}
+	failures      map[string]string
+	requests      map[string]int
+	lastID        int
+}
+
+// NewServer starts a fake NerdGraph server with an empty organization, it should be closed after use.
+func NewServer() *Server {
+	s := &Server{
+		PageSize: defaultPageSize,
+		orgID:    "org-1",
+		orgName:  "Test Org",
+		events:   make(map[int][]map[string]interface{}),
+		failures: make(map[string]string),
+		requests: make(map[string]int),
+		lastID:   1000,
+	}
+
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// Client returns NewRelic client sending requests to the fake server.
+func (s *Server) Client(ctx context.Context, opts ...newrelic.Option) (*newrelic.Client, error) {
+	return newrelic.NewClient(ctx, s.Server.Client(), "test-api-key", append(opts, newrelic.WithBaseURL(s.URL))...)
+}
+
+// SetOrg changes id and name of the organization.
+func (s *Server) SetOrg(id, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.orgID, s.orgName = id, name
+}
+
+// SetCurrentUser sets the user owning the API key used by the client.
+func (s *Server) SetCurrentUser(userId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.currentUserID = userId
+}
+
+func (s *Server) AddAccount(account Account) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.accounts = append(s.accounts, account)
+}
+
+func (s *Server) AddDomain(domain Domain) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.domains = append(s.domains, &domain)
+}
+
+func (s *Server) AddUser(user User) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.users = append(s.users, &user)
+}
+
+func (s *Server) AddGroup(group Group) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.groups = append(s.groups, &group)
+}
+
+func (s *Server) AddRole(role Role) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roles = append(s.roles, &role)
+}
+
+func (s *Server) AddPermission(permission Permission) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.permissions = append(s.permissions, permission)
+}
+
+// AddRoleGrant grants the role to the group, id of the grant is generated if empty.
+func (s *Server) AddRoleGrant(grant RoleGrant) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if grant.ID == "" {
+		grant.ID = s.newID()
+	}
+
+	s.grants = append(s.grants, &grant)
+}
+
+func (s *Server) AddAPIKey(key APIKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, &key)
+}
+
+// AddAuditEvent records NrAuditEvent of the account, attributes should contain timestamp in epoch milliseconds.
+func (s *Server) AddAuditEvent(accountId int, attributes map[string]interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.events[accountId] = append(s.events[accountId], attributes)
+}
+
+// FailOperation makes requests of the operation fail with the NerdGraph error class, empty class clears the failure.
+func (s *Server) FailOperation(operation, errorClass string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if errorClass == "" {
+		delete(s.failures, operation)
+		return
+	}
+
+	s.failures[operation] = errorClass
+}
+
+// Requests returns the number of requests of the operation received by the server.
+func (s *Server) Requests(operation string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.requests[operation]
+}
+
+// User returns copy of the user.
+func (s *Server) User(id string) (User, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if u := s.findUser(id); u != nil {
+		return *u, true
+	}
+
+	return User{}, false
+}
+
+// Group returns copy of the group.
+func (s *Server) Group(id string) (Group, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if g := s.findGroup(id); g != nil {
+		rv := *g
+		rv.Members = append([]string(nil), g.Members...)
+		return rv, true
+	}
+
+	return Group{}, false
+}
+
+// Role returns copy of the role.
+func (s *Server) Role(id string) (Role, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r := s.findRole(id); r != nil {
+		rv := *r
+		rv.PermissionIDs = append([]int(nil), r.PermissionIDs...)
+		return rv, true
+	}
+
+	return Role{}, false
+}
+
+// Members returns sorted ids of members of the group.
+func (s *Server) Members(groupId string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	g := s.findGroup(groupId)
+	if g == nil {
+		return nil
+	}
+
+	rv := append([]string(nil), g.Members...)
+	sort.Strings(rv)
+
+	return rv
+}
+
+// RoleGrants returns grants of roles to the group.
+func (s *Server) RoleGrants(groupId string) []RoleGrant {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var rv []RoleGrant
+	for _, g := range s.grants {
+		if g.GroupID == groupId {
+			rv = append(rv, *g)
+		}
+	}
+
+	return rv
+}
+
+// APIKey returns copy of the key.
+func (s *Server) APIKey(id string) (APIKey, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, k := range s.keys {
+		if k.ID == id {
+			return *k, true
+		}
+	}
+
+	return APIKey{}, false
+}
+
+var operationRe = regexp.MustCompile(`^\s*(?:query|mutation)\s+(\w+)`)
+
+type request struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+// gqlError is returned by handlers to respond with NerdGraph error of the class.
+type gqlError struct {
+	class   string
+	message string
+}
+
+func (e *gqlError) Error() string {
+	return e.message
+}
+
+func errorf(class, format string, args ...interface{}) error {
+	return &gqlError{class: class, message: fmt.Sprintf(format, args...)}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var req request
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	match := operationRe.FindStringSubmatch(req.Query)
+	if match == nil {
+		writeError(w, errorf("GRAPHQL_PARSE_FAILED", "operation name is required"))
+		return
+	}
+
+	operation := match[1]
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[operation]++
+
+	if class, ok := s.failures[operation]; ok {
+		writeError(w, errorf(class, "operation %s failed", operation))
+		return
+	}
+
+	handler, ok := handlers[operation]
+	if !ok {
+		writeError(w, errorf("GRAPHQL_VALIDATION_FAILED", "unknown operation %s", operation))
+		return
+	}
+
+	data, err := handler(s, vars(req.Variables))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	class := "INTERNAL_SERVER_ERROR"
+	if gErr, ok := err.(*gqlError); ok {
+		class = gErr.class
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"data": nil,
+		"errors": []interface{}{
+			map[string]interface{}{
+				"message":    err.Error(),
+				"extensions": map[string]interface{}{"errorClass": class},
+			},
+		},
+	})
+}
+
+// vars reads request variables, ids passed as lists are read as their first item.
+type vars map[string]interface{}
+
+func (v vars) str(name string) string {
+	switch value := v[name].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case []interface{}:
+		if len(value) > 0 {
+			return vars{name: value[0]}.str(name)
+		}
+	}
+
+	return ""
+}
+
+func (v vars) num(name string) int {
+	n, _ := strconv.Atoi(v.str(name))
+	return n
+}
+
+func (v vars) list(name string) []string {
+	values, ok := v[name].([]interface{})
+	if !ok && v[name] != nil {
+		// single value is coerced to a list, as GraphQL does for list inputs
+		values = []interface{}{v[name]}
+	}
+
+	var rv []string
+	for _, value := range values {
+		rv = append(rv, vars{name: value}.str(name))
+	}
+
+	return rv
+}
+
+// paginate returns bounds of the page of n items starting at the cursor and cursor of the next page.
+// Cursors contain a colon to catch clients splitting them.
+func (s *Server) paginate(n int, cursor string) (int, int, string, error) {
+	start := 0
+	if cursor != "" {
+		if _, err := fmt.Sscanf(cursor, "cursor:%d", &start); err != nil || start < 0 || start > n {
+			return 0, 0, "", errorf("BAD_USER_INPUT", "invalid cursor %s", cursor)
+		}
+	}
+
+	size := s.PageSize
+	if size <= 0 {
+		size = defaultPageSize
+	}
+
+	end := start + size
+	if end >= n {
+		return start, n, "", nil
+	}
+
+	return start, end, fmt.Sprintf("cursor:%d", end), nil
+}
+
+func (s *Server) newID() string {
+	s.lastID++
+	return strconv.Itoa(s.lastID)
+}
+
+func (s *Server) findUser(id string) *User {
+	for _, u := range s.users {
+		if u.ID == id {
+			return u
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) findGroup(id string) *Group {
+	for _, g := range s.groups {
+		if g.ID == id {
+			return g
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) findRole(id string) *Role {
+	for _, r := range s.roles {
+		if r.ID == id {
+			return r
+		}
+	}
+
+	return nil
+}
+
+func (s *Server) findDomain(id string) *Domain {
+	for _, d := range s.domains {
+		if d.ID == id {
+			return d
+		}
+	}
+
+	return nil
+}
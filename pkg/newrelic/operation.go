@@ -0,0 +1,301 @@
+package newrelic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// operation is a GraphQL query or mutation. Variables of the operation are not declared by hand,
+// they are collected from arguments of its fields, so declarations can't get out of sync with their use.
+type operation struct {
+	kind      string
+	name      string
+	selection []field
+}
+
+func query(name string, selection ...selector) operation {
+	return operation{kind: "query", name: name, selection: selectFields(selection)}
+}
+
+func mutation(name string, selection ...selector) operation {
+	return operation{kind: "mutation", name: name, selection: selectFields(selection)}
+}
+
+// Name returns the operation name.
+func (o operation) Name() string {
+	return o.name
+}
+
+// variables returns variables used by the operation in order of their first use.
+// Using the same variable name with different types is a programming error and panics.
+func (o operation) variables() []variable {
+	var rv []variable
+	seen := make(map[string]variable)
+
+	var visit func(v value)
+	visit = func(v value) {
+		switch v := v.(type) {
+		case variable:
+			if prev, ok := seen[v.name]; ok {
+				if prev.typ != v.typ {
+					panic(fmt.Sprintf("newrelic: variable $%s of %s declared as both %s and %s", v.name, o.name, prev.typ, v.typ))
+				}
+
+				return
+			}
+
+			seen[v.name] = v
+			rv = append(rv, v)
+		case object:
+			for _, a := range v {
+				visit(a.value)
+			}
+		case list:
+			for _, item := range v {
+				visit(item)
+			}
+		}
+	}
+
+	var walk func(fields []field)
+	walk = func(fields []field) {
+		for _, f := range fields {
+			for _, a := range f.arguments {
+				visit(a.value)
+			}
+
+			walk(f.selection)
+		}
+	}
+
+	walk(o.selection)
+
+	return rv
+}
+
+// validate checks variables of a request against the variables used by the operation.
+// Variables the operation doesn't use and missing values of required variables are rejected.
+func (o operation) validate(values map[string]interface{}) error {
+	declared := make(map[string]variable)
+	for _, v := range o.variables() {
+		declared[v.name] = v
+
+		if v.required() && values[v.name] == nil {
+			return fmt.Errorf("newrelic: missing required variable $%s of %s", v.name, o.name)
+		}
+	}
+
+	for name := range values {
+		if _, ok := declared[name]; !ok {
+			return fmt.Errorf("newrelic: variable $%s is not used by %s", name, o.name)
+		}
+	}
+
+	return nil
+}
+
+// String renders the operation as GraphQL document.
+func (o operation) String() string {
+	var b strings.Builder
+
+	b.WriteString(o.kind)
+	b.WriteString(" ")
+	b.WriteString(o.name)
+
+	if vars := o.variables(); len(vars) > 0 {
+		b.WriteString("(")
+		for i, v := range vars {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+
+			b.WriteString("$")
+			b.WriteString(v.name)
+			b.WriteString(": ")
+			b.WriteString(v.typ)
+		}
+		b.WriteString(")")
+	}
+
+	writeSelection(&b, o.selection, 0)
+	b.WriteString("\n")
+
+	return b.String()
+}
+
+// field is a field of a selection set, or an inline fragment if typeCondition is set.
+type field struct {
+	alias         string
+	name          string
+	typeCondition string
+	arguments     []argument
+	selection     []field
+}
+
+// selector is a part of a selection set, either a single field or a list of scalar fields.
+type selector interface {
+	fields() []field
+}
+
+func selectFields(selection []selector) []field {
+	var rv []field
+	for _, s := range selection {
+		rv = append(rv, s.fields()...)
+	}
+
+	return rv
+}
+
+// fld returns field with the sub-selection.
+func fld(name string, selection ...selector) field {
+	return field{name: name, selection: selectFields(selection)}
+}
+
+// on returns inline fragment selecting fields of the type.
+func on(typeName string, selection ...selector) field {
+	return field{typeCondition: typeName, selection: selectFields(selection)}
+}
+
+func (f field) fields() []field {
+	return []field{f}
+}
+
+// args returns copy of the field with the arguments.
+func (f field) args(arguments ...argument) field {
+	f.arguments = append(append([]argument(nil), f.arguments...), arguments...)
+	return f
+}
+
+// as returns copy of the field with the alias.
+func (f field) as(alias string) field {
+	f.alias = alias
+	return f
+}
+
+// scalars is a list of fields without sub-selection.
+type scalars []string
+
+func (s scalars) fields() []field {
+	rv := make([]field, 0, len(s))
+	for _, name := range s {
+		rv = append(rv, field{name: name})
+	}
+
+	return rv
+}
+
+func writeSelection(b *strings.Builder, fields []field, depth int) {
+	if len(fields) == 0 {
+		return
+	}
+
+	b.WriteString(" {\n")
+	for _, f := range fields {
+		b.WriteString(strings.Repeat("  ", depth+1))
+
+		if f.typeCondition != "" {
+			b.WriteString("... on ")
+			b.WriteString(f.typeCondition)
+		} else {
+			if f.alias != "" {
+				b.WriteString(f.alias)
+				b.WriteString(": ")
+			}
+
+			b.WriteString(f.name)
+		}
+
+		if len(f.arguments) > 0 {
+			b.WriteString("(")
+			writeArguments(b, f.arguments)
+			b.WriteString(")")
+		}
+
+		writeSelection(b, f.selection, depth+1)
+		b.WriteString("\n")
+	}
+	b.WriteString(strings.Repeat("  ", depth))
+	b.WriteString("}")
+}
+
+func writeArguments(b *strings.Builder, arguments []argument) {
+	for i, a := range arguments {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		b.WriteString(a.name)
+		b.WriteString(": ")
+		a.value.write(b)
+	}
+}
+
+// argument is an argument of a field or a field of an input object.
+type argument struct {
+	name  string
+	value value
+}
+
+func arg(name string, v value) argument {
+	return argument{name: name, value: v}
+}
+
+// value is a value of an argument.
+type value interface {
+	write(b *strings.Builder)
+}
+
+// variable is a variable of an operation with its GraphQL type.
+type variable struct {
+	name string
+	typ  string
+}
+
+func (v variable) write(b *strings.Builder) {
+	b.WriteString("$")
+	b.WriteString(v.name)
+}
+
+// required reports whether the variable has non-null type.
+func (v variable) required() bool {
+	return strings.HasSuffix(v.typ, "!")
+}
+
+// enum is an enum value.
+type enum string
+
+func (e enum) write(b *strings.Builder) {
+	b.WriteString(string(e))
+}
+
+// str is a string literal.
+type str string
+
+func (s str) write(b *strings.Builder) {
+	b.WriteString(strconv.Quote(string(s)))
+}
+
+// object is an input object.
+type object []argument
+
+func (o object) write(b *strings.Builder) {
+	b.WriteString("{")
+	writeArguments(b, o)
+	b.WriteString("}")
+}
+
+// list is a list of values.
+type list []value
+
+func (l list) write(b *strings.Builder) {
+	b.WriteString("[")
+	for i, v := range l {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+
+		v.write(b)
+	}
+	b.WriteString("]")
+}
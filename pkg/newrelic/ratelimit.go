@@ -0,0 +1,111 @@
+package newrelic
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 5
+	minRetryDelay     = 500 * time.Millisecond
+	maxRetryDelay     = 30 * time.Second
+)
+
+// RateLimitState describes rate limiting observed by the client on NerdGraph requests.
+type RateLimitState struct {
+	// Throttled is set when the latest request was throttled and no request succeeded since.
+	Throttled bool
+	// Limit is the configured client-side cap of requests per second (0 if not capped).
+	Limit int64
+	// ResetAt is the time after which the client expects to be able to send requests again.
+	ResetAt time.Time
+}
+
+type rateLimitTracker struct {
+	mu    sync.Mutex
+	state RateLimitState
+}
+
+func (t *rateLimitTracker) throttled(resetAt time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state.Throttled = true
+	if resetAt.After(t.state.ResetAt) {
+		t.state.ResetAt = resetAt
+	}
+}
+
+func (t *rateLimitTracker) ok() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.state.Throttled = false
+	t.state.ResetAt = time.Time{}
+}
+
+func (t *rateLimitTracker) get() RateLimitState {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.state
+}
+
+// isRetryable reports whether the request can be safely sent again.
+// Only throttled requests are retried since they were not processed by NerdGraph,
+// retrying other failures could apply mutations twice.
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrThrottled)
+}
+
+// retryDelay returns how long to wait before next attempt. Retry-After sent by NerdGraph
+// takes precedence, otherwise exponential backoff with full jitter is used.
+func retryDelay(attempt int, err error) time.Duration {
+	var rErr *RequestError
+	if errors.As(err, &rErr) && rErr.RetryAfter > 0 {
+		return rErr.RetryAfter
+	}
+
+	backoff := minRetryDelay << attempt
+	if backoff <= 0 || backoff > maxRetryDelay {
+		backoff = maxRetryDelay
+	}
+
+	//nolint:gosec // jitter does not need cryptographically secure randomness
+	return minRetryDelay/2 + time.Duration(rand.Int63n(int64(backoff)))
+}
+
+// parseRetryAfter parses Retry-After header in both delay-seconds and http-date format.
+func parseRetryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if t, err := http.ParseTime(value); err == nil {
+		return time.Until(t)
+	}
+
+	return 0
+}
+
+func wait(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,35 @@
+package newrelic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/vektah/gqlparser/v2"
+	"github.com/vektah/gqlparser/v2/ast"
+)
+
+// TestOperationsMatchSchema validates every operation sent by the client against the snapshot
+// of NerdGraph schema, so removed or changed fields fail here instead of in a sync.
+func TestOperationsMatchSchema(t *testing.T) {
+	path := filepath.Join("testdata", "nerdgraph.graphql")
+
+	sdl, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read schema: %v", err)
+	}
+
+	schema, gqlErr := gqlparser.LoadSchema(&ast.Source{Name: path, Input: string(sdl)})
+	if gqlErr != nil {
+		t.Fatalf("failed to load schema: %v", gqlErr)
+	}
+
+	for _, op := range operations() {
+		t.Run(op.Name(), func(t *testing.T) {
+			_, errs := gqlparser.LoadQuery(schema, op.String())
+			for _, err := range errs {
+				t.Errorf("%s: %v", op.Name(), err)
+			}
+		})
+	}
+}